@@ -8,6 +8,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/winterRel/helm-schema/pkg/schema"
 )
 
 func possibleLogLevels() []string {
@@ -32,11 +34,27 @@ func configureLogging() {
 	log.SetLevel(logLevel)
 }
 
-func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+// existingFiles accepts the changed files pre-commit passes (they are ignored), but reports
+// other arguments like mistyped subcommands
+func existingFiles(cmd *cobra.Command, args []string) error {
+	for _, arg := range args {
+		if _, err := os.Stat(arg); err != nil {
+			return fmt.Errorf("unknown command or file %q for %q", arg, cmd.CommandPath())
+		}
+	}
+	return nil
+}
+
+func newCommand(run, validate, docs func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
 	cmd := &cobra.Command{
-		Use:           "helm-schema",
-		Short:         "helm-schema automatically generates a jsonschema file for helm charts from values files",
-		Version:       version,
+		Use:     "helm-schema",
+		Short:   "helm-schema automatically generates a jsonschema file for helm charts from values files",
+		Args:    existingFiles,
+		Version: version,
+		// the subcommands share flags like value-files, so only the flags of the executed command are bound
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
 		RunE:          run,
 		SilenceUsage:  true,
 		SilenceErrors: true,
@@ -48,9 +66,13 @@ func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Comma
 	)
 	cmd.PersistentFlags().
 		StringP("chart-search-root", "c", ".", "directory to search recursively within for charts")
-	cmd.PersistentFlags().
+	cmd.Flags().
 		BoolP("dry-run", "d", false, "don't actually create files just print to stdout passed")
-	cmd.PersistentFlags().
+	cmd.Flags().
+		Bool("check", false, "don't write any files, but fail if a jsonschema is out of date and print the diff")
+	cmd.Flags().
+		Bool("ordered-output", false, "keep the order of the values file for properties and write keywords in a fixed order")
+	cmd.Flags().
 		BoolP("append-newline", "a", false, "append newline to generated jsonschema at the end of the file")
 	cmd.PersistentFlags().
 		BoolP("keep-full-comment", "s", false, "keep the whole leading comment (default: cut at empty line)")
@@ -63,19 +85,86 @@ func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Comma
 	cmd.PersistentFlags().
 		BoolP("no-dependencies", "n", false, "don't analyze dependencies")
 	cmd.PersistentFlags().
-		BoolP("add-schema-reference", "r", false, "add reference to schema in values.yaml if not found")
-	cmd.PersistentFlags().StringP("log-level", "l", "info", logLevelUsage)
+		Bool("dependency-refs", false, "place the jsonschema of each dependency once in $defs and reference it instead of inlining it")
 	cmd.PersistentFlags().
-		StringSliceP("value-files", "f", []string{"values.yaml"}, "filenames to check for chart values")
+		Bool("warn-invalid-defaults", false, "only warn about enum values, defaults and examples which don't match their jsonschema instead of failing")
 	cmd.PersistentFlags().
+		Bool("no-self-check", false, "don't validate the values file against the generated jsonschema")
+	cmd.Flags().
+		BoolP("add-schema-reference", "r", false, "add reference to schema in values.yaml if not found")
+	addSearchFlags(cmd)
+	cmd.PersistentFlags().StringP("log-level", "l", "info", logLevelUsage)
+	addValueFilesFlags(cmd, "f")
+	cmd.Flags().
 		StringP("output-file", "o", "values.schema.json", "jsonschema file path relative to each chart directory to which jsonschema will be written")
+	cmd.PersistentFlags().
+		String("draft", "7", fmt.Sprintf("jsonschema draft of the generated schema, one of (%s)", strings.Join(schema.PossibleDrafts, ", ")))
 	cmd.PersistentFlags().
 		StringSliceP("skip-auto-generation", "k", []string{}, "comma separated list of fields to skip from being created by default (possible: title, description, required, default, additionalProperties)")
 
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("HELM_SCHEMA")
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	err := viper.BindPFlags(cmd.PersistentFlags())
 
-	return cmd, err
+	cmd.AddCommand(newValidateCommand(validate), newDocsCommand(docs))
+
+	return cmd, nil
+}
+
+// addSearchFlags adds the flags selecting the charts below the chart search root
+func addSearchFlags(cmd *cobra.Command) {
+	cmd.Flags().
+		StringSlice("exclude", []string{}, "glob patterns (relative to the chart search root) of files and directories which aren't searched for charts")
+	cmd.Flags().
+		StringSlice("include", []string{}, "glob patterns (relative to the chart search root) of the only chart directories used")
+	cmd.Flags().
+		Bool("respect-ignore-files", false, "don't search files and directories ignored by .gitignore and .helmignore files")
+}
+
+// addValueFilesFlags adds the flags selecting the values files the jsonschema is generated from
+func addValueFilesFlags(cmd *cobra.Command, shorthand string) {
+	cmd.Flags().
+		StringSliceP("value-files", shorthand, []string{"values.yaml"}, "filenames to check for chart values")
+	cmd.Flags().
+		Bool("merge-value-files", false, "merge all existing value files into one jsonschema instead of using the first one")
+}
+
+func newValidateCommand(run func(cmd *cobra.Command, args []string) error) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "validate [chart]",
+		Short:         "validate values files against the jsonschema of a chart (default: current directory)",
+		Args:          cobra.MaximumNArgs(1),
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().
+		StringSliceP("values", "f", []string{}, "values files to validate")
+	cmd.Flags().
+		String("schema", "", "jsonschema file to validate against (default: generate it from the chart)")
+	// -f selects the values files to validate
+	addValueFilesFlags(cmd, "")
+
+	return cmd
+}
+
+func newDocsCommand(run func(cmd *cobra.Command, args []string) error) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "docs",
+		Short:         "generate markdown documentation of the values of every chart from the same annotations",
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().
+		String("template", "", "go template file used to render the documentation (default: builtin values table)")
+	cmd.Flags().
+		String("docs-file", "VALUES.md", "markdown file path relative to each chart directory to which the documentation will be written (only between the helm-schema-docs markers if the file has them), - prints to stdout")
+	addSearchFlags(cmd)
+	addValueFilesFlags(cmd, "f")
+
+	return cmd
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/winterRel/helm-schema/pkg/config"
+	"github.com/winterRel/helm-schema/pkg/docs"
+)
+
+func generateDocs(cmd *cobra.Command, _ []string) error {
+	configureLogging()
+
+	chartSearchRoot := viper.GetString("chart-search-root")
+	docsFile := viper.GetString("docs-file")
+
+	tmpl := docs.DefaultTemplate
+	if templateFile := viper.GetString("template"); templateFile != "" {
+		content, err := os.ReadFile(templateFile)
+		if err != nil {
+			return err
+		}
+		tmpl = string(content)
+	}
+
+	cfg, err := config.Load(chartSearchRoot)
+	if err != nil {
+		return err
+	}
+
+	generator, err := newGenerator(cfg)
+	if err != nil {
+		return err
+	}
+
+	filter, err := newFileFilter(chartSearchRoot)
+	if err != nil {
+		return err
+	}
+
+	results, err := generateSchemas(generator, chartSearchRoot, filter)
+	if err != nil {
+		return err
+	}
+
+	foundErrors := false
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			foundErrors = true
+			logResultErrors(result)
+			continue
+		}
+		if result.Archive {
+			continue
+		}
+
+		var rendered bytes.Buffer
+		if err := docs.Render(&rendered, tmpl, result.Chart, &result.Schema); err != nil {
+			log.Errorf("Could not render the documentation of %s chart: %s", result.Chart.Name, err)
+			foundErrors = true
+			continue
+		}
+
+		if docsFile == "-" {
+			log.Infof("Printing documentation for %s chart (%s)", result.Chart.Name, result.ChartPath)
+			fmt.Print(rendered.String())
+			continue
+		}
+
+		docsPath := filepath.Join(filepath.Dir(result.ChartPath), docsFile)
+		content := rendered.Bytes()
+		existing, err := os.ReadFile(docsPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error(err)
+			foundErrors = true
+			continue
+		}
+		if inserted, ok := docs.Insert(existing, content); ok {
+			content = inserted
+		}
+		if err := os.WriteFile(docsPath, content, 0644); err != nil {
+			log.Error(err)
+			foundErrors = true
+			continue
+		}
+		log.Debugf("Wrote documentation of %s chart to %s", result.Chart.Name, docsPath)
+	}
+
+	if foundErrors {
+		return errors.New("some errors were found")
+	}
+	return nil
+}
@@ -1,22 +1,109 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
-	"strings"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/winterRel/helm-schema/pkg/config"
 	"github.com/winterRel/helm-schema/pkg/schema"
+	"github.com/winterRel/helm-schema/pkg/util"
 )
 
-func searchFiles(startPath, fileName string, queue chan<- string, errs chan<- error) {
+// isChartArchive checks if the path is a packaged chart inside of a charts directory
+func isChartArchive(path string) bool {
+	return filepath.Ext(path) == ".tgz" && filepath.Base(filepath.Dir(path)) == "charts"
+}
+
+// fileFilter decides which files and directories are searched for charts
+type fileFilter struct {
+	root    string
+	include *util.PathMatcher
+	exclude *util.PathMatcher
+	// ignorer is nil if ignore files aren't respected
+	ignorer *util.Ignorer
+}
+
+// newFileFilter creates the filter for the chart search in root configured by the cli flags
+func newFileFilter(root string) (*fileFilter, error) {
+	var include, exclude []string
+	if err := viper.UnmarshalKey("include", &include); err != nil {
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("exclude", &exclude); err != nil {
+		return nil, err
+	}
+
+	filter := &fileFilter{root: root}
+	var err error
+	if len(include) > 0 {
+		if filter.include, err = util.NewPathMatcher(include); err != nil {
+			return nil, err
+		}
+	}
+	if filter.exclude, err = util.NewPathMatcher(exclude); err != nil {
+		return nil, err
+	}
+	if viper.GetBool("respect-ignore-files") {
+		filter.ignorer = util.NewIgnorer(root, ".gitignore", ".helmignore")
+	}
+	return filter, nil
+}
+
+// skip checks if the file or directory shouldn't be searched
+func (f *fileFilter) skip(path string, isDir bool) (bool, error) {
+	if f == nil {
+		return false, nil
+	}
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil || rel == "." {
+		return false, err
+	}
+	if f.exclude.Match(filepath.ToSlash(rel), isDir) {
+		return true, nil
+	}
+	if f.ignorer != nil && !f.inDependencies(path) {
+		return f.ignorer.Ignored(path, isDir)
+	}
+	return false, nil
+}
+
+// inDependencies checks if the path is the charts directory of a chart or inside of it. The
+// dependencies are often git ignored, but needed to merge them into their parents.
+func (f *fileFilter) inDependencies(path string) bool {
+	for dir := path; dir != f.root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) != "charts" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "Chart.yaml")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// included checks if the chart at path matches the include patterns (if any)
+func (f *fileFilter) included(path string) bool {
+	if f == nil || f.include == nil {
+		return true
+	}
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil {
+		return false
+	}
+	return f.include.Match(filepath.ToSlash(rel), true)
+}
+
+func searchFiles(startPath, fileName string, filter *fileFilter, queue chan<- string, errs chan<- error) {
 	defer close(queue)
 	err := filepath.Walk(startPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -24,7 +111,28 @@ func searchFiles(startPath, fileName string, queue chan<- string, errs chan<- er
 			return nil
 		}
 
-		if !info.IsDir() && info.Name() == fileName {
+		skip, err := filter.skip(path, info.IsDir())
+		if err != nil {
+			errs <- err
+			return nil
+		}
+		if skip {
+			log.Debugf("Skipping %s", path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.IsDir() && (info.Name() == fileName || isChartArchive(path)) {
+			chartPath := path
+			if info.Name() == fileName {
+				chartPath = filepath.Dir(path)
+			}
+			if !filter.included(chartPath) {
+				log.Debugf("Skipping %s, because it isn't included", path)
+				return nil
+			}
 			queue <- path
 		}
 
@@ -35,34 +143,138 @@ func searchFiles(startPath, fileName string, queue chan<- string, errs chan<- er
 	}
 }
 
-func exec(cmd *cobra.Command, _ []string) error {
-	configureLogging()
+// diffSchema compares the generated jsonschema semantically with the one in schemaPath
+// and returns a unified diff if they differ
+func diffSchema(schemaPath string, generated []byte) (string, error) {
+	var generatedData interface{}
+	if err := json.Unmarshal(generated, &generatedData); err != nil {
+		return "", err
+	}
+	generatedNormalized, err := json.MarshalIndent(generatedData, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	generatedNormalized = append(generatedNormalized, '\n')
 
-	var skipAutoGeneration, valueFileNames []string
+	existing, err := os.ReadFile(schemaPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		return util.UnifiedDiff("/dev/null", schemaPath, nil, generatedNormalized), nil
+	}
 
-	chartSearchRoot := viper.GetString("chart-search-root")
-	dryRun := viper.GetBool("dry-run")
-	noDeps := viper.GetBool("no-dependencies")
-	addSchemaReference := viper.GetBool("add-schema-reference")
-	keepFullComment := viper.GetBool("keep-full-comment")
-	helmDocsCompatibilityMode := viper.GetBool("helm-docs-compatibility-mode")
-	uncomment := viper.GetBool("uncomment")
-	outFile := viper.GetString("output-file")
-	dontRemoveHelmDocsPrefix := viper.GetBool("dont-strip-helm-docs-prefix")
-	appendNewline := viper.GetBool("append-newline")
-	if err := viper.UnmarshalKey("value-files", &valueFileNames); err != nil {
-		return err
+	var existingData interface{}
+	if err := json.Unmarshal(existing, &existingData); err != nil {
+		return "", fmt.Errorf("could not parse %s: %w", schemaPath, err)
 	}
-	if err := viper.UnmarshalKey("skip-auto-generation", &skipAutoGeneration); err != nil {
-		return err
+	if reflect.DeepEqual(existingData, generatedData) {
+		return "", nil
+	}
+
+	existingNormalized, err := json.MarshalIndent(existingData, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	existingNormalized = append(existingNormalized, '\n')
+
+	return util.UnifiedDiff(schemaPath, schemaPath+" (generated)", existingNormalized, generatedNormalized), nil
+}
+
+// stringSliceOption returns the value of the flag key, or configValue if it is set and the flag isn't
+func stringSliceOption(key string, configValue []string) ([]string, error) {
+	if configValue != nil && !viper.IsSet(key) {
+		return configValue, nil
+	}
+	var value []string
+	err := viper.UnmarshalKey(key, &value)
+	return value, err
+}
+
+// boolOption returns the value of the flag key, or configValue if it is set and the flag isn't
+func boolOption(key string, configValue *bool) bool {
+	if configValue != nil && !viper.IsSet(key) {
+		return *configValue
+	}
+	return viper.GetBool(key)
+}
+
+// stringOption returns the value of the flag key, or configValue if it is set and the flag isn't
+func stringOption(key string, configValue *string) string {
+	if configValue != nil && !viper.IsSet(key) {
+		return *configValue
+	}
+	return viper.GetString(key)
+}
+
+// addSchemaReference checks if the schema reference should be added to the values files,
+// which is never done by runs which mustn't write files (--check and --dry-run)
+func addSchemaReference() bool {
+	return viper.GetBool("add-schema-reference") && !viper.GetBool("check") && !viper.GetBool("dry-run")
+}
+
+// generatorOptions creates the generator options from the cli flags and the chart config,
+// flags which are set explicitly take precedence over the config
+func generatorOptions(chartConfig config.ChartConfig) (schema.GeneratorOptions, error) {
+	valueFileNames, err := stringSliceOption("value-files", chartConfig.ValueFiles)
+	if err != nil {
+		return schema.GeneratorOptions{}, err
+	}
+	skipAutoGeneration, err := stringSliceOption("skip-auto-generation", chartConfig.SkipAutoGeneration)
+	if err != nil {
+		return schema.GeneratorOptions{}, err
 	}
-	workersCount := runtime.NumCPU() * 2
 
 	skipConfig, err := schema.NewSkipAutoGenerationConfig(skipAutoGeneration)
 	if err != nil {
-		return err
+		return schema.GeneratorOptions{}, err
+	}
+
+	draft, err := schema.ParseDraft(viper.GetString("draft"))
+	if err != nil {
+		return schema.GeneratorOptions{}, err
+	}
+
+	return schema.GeneratorOptions{
+		ValueFileNames:            valueFileNames,
+		Uncomment:                 viper.GetBool("uncomment"),
+		AddSchemaReference:        addSchemaReference(),
+		KeepFullComment:           viper.GetBool("keep-full-comment"),
+		HelmDocsCompatibilityMode: boolOption("helm-docs-compatibility-mode", chartConfig.HelmDocsCompatibilityMode),
+		DontRemoveHelmDocsPrefix:  boolOption("dont-strip-helm-docs-prefix", chartConfig.DontStripHelmDocsPrefix),
+		SkipAutoGeneration:        *skipConfig,
+		Draft:                     draft,
+		NoDependencies:            boolOption("no-dependencies", chartConfig.NoDependencies),
+		MergeValueFiles:           boolOption("merge-value-files", chartConfig.MergeValueFiles),
+		DependencyRefs:            boolOption("dependency-refs", chartConfig.DependencyRefs),
+		WarnInvalidDefaults:       boolOption("warn-invalid-defaults", chartConfig.WarnInvalidDefaults),
+		NoSelfCheck:               boolOption("no-self-check", chartConfig.NoSelfCheck),
+	}, nil
+}
+
+// newGenerator creates the generator configured by the cli flags and the config file
+func newGenerator(cfg *config.Config) (*schema.Generator, error) {
+	options, err := generatorOptions(cfg.ChartConfig)
+	if err != nil {
+		return nil, err
 	}
 
+	generator := schema.NewGenerator(options)
+	generator.SetChartOptions(func(chartDir string) (schema.GeneratorOptions, error) {
+		chartConfig, err := cfg.ForChart(chartDir)
+		if err != nil {
+			return schema.GeneratorOptions{}, err
+		}
+		return generatorOptions(chartConfig)
+	})
+	return generator, nil
+}
+
+// generateSchemas creates the jsonschemas of all charts found in chartSearchRoot (filtered by filter, if set)
+// and merges the dependencies into their parents
+func generateSchemas(generator *schema.Generator, chartSearchRoot string, filter *fileFilter) ([]*schema.Result, error) {
+	workersCount := runtime.NumCPU() * 2
+
 	// 1. Start a producer that searches Chart.yaml and values.yaml files
 	queue := make(chan string)
 	resultsChan := make(chan schema.Result)
@@ -70,7 +282,7 @@ func exec(cmd *cobra.Command, _ []string) error {
 	errs := make(chan error)
 	done := make(chan struct{})
 
-	go searchFiles(chartSearchRoot, "Chart.yaml", queue, errs)
+	go searchFiles(chartSearchRoot, "Chart.yaml", filter, queue, errs)
 
 	// 2. Start workers and every worker does:
 	wg := sync.WaitGroup{}
@@ -84,19 +296,7 @@ func exec(cmd *cobra.Command, _ []string) error {
 
 		go func() {
 			defer wg.Done()
-			schema.Worker(
-				dryRun,
-				uncomment,
-				addSchemaReference,
-				keepFullComment,
-				helmDocsCompatibilityMode,
-				dontRemoveHelmDocsPrefix,
-				valueFileNames,
-				skipConfig,
-				outFile,
-				queue,
-				resultsChan,
-			)
+			schema.Worker(generator, queue, resultsChan)
 		}()
 	}
 
@@ -113,128 +313,96 @@ loop:
 		}
 	}
 
-	// sort results with topology sort (only if we're checking the dependencies)
-	if !noDeps {
-		// sort results with topology sort
-		results, err = schema.TopoSort(results)
-		if err != nil {
-			if _, ok := err.(*schema.CircularError); !ok {
-				log.Errorf("Error while sorting results: %s", err)
-				return err
-			} else {
-				log.Warnf("Could not sort results: %s", err)
-			}
-		}
+	// 3. Merge the dependencies into their parents (skips charts which don't check their dependencies)
+	results, err := generator.ResolveDependencies(results)
+	if err != nil {
+		log.Errorf("Error while sorting results: %s", err)
+		return nil, err
 	}
 
-	conditionsToPatch := make(map[string][]string)
-	// Sort results if dependencies should be processed
-	// Need to resolve the dependencies from deepest level to highest
+	return results, nil
+}
 
-	if !noDeps {
-		// Iterate over deps to find conditions we need to patch (dependencies that have a condition)
-		for _, result := range results {
-			if len(result.Errors) > 0 {
-				continue
-			}
-			for _, dep := range result.Chart.Dependencies {
-				if dep.Condition != "" {
-					conditionKeys := strings.Split(dep.Condition, ".")
-					conditionsToPatch[conditionKeys[0]] = conditionKeys[1:]
-				}
-			}
-		}
+// logResultErrors logs all errors found while processing the chart of the result
+func logResultErrors(result *schema.Result) {
+	if result.Chart != nil {
+		log.Errorf(
+			"Found %d errors while processing the chart %s (%s)",
+			len(result.Errors),
+			result.Chart.Name,
+			result.ChartPath,
+		)
+	} else {
+		log.Errorf("Found %d errors while processing the chart %s", len(result.Errors), result.ChartPath)
+	}
+	for _, err := range result.Errors {
+		log.Error(err)
+	}
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	configureLogging()
+
+	chartSearchRoot := viper.GetString("chart-search-root")
+	dryRun := viper.GetBool("dry-run")
+	check := viper.GetBool("check")
+	appendNewline := viper.GetBool("append-newline")
+	orderedOutput := viper.GetBool("ordered-output")
+
+	cfg, err := config.Load(chartSearchRoot)
+	if err != nil {
+		return err
+	}
+
+	generator, err := newGenerator(cfg)
+	if err != nil {
+		return err
+	}
+
+	filter, err := newFileFilter(chartSearchRoot)
+	if err != nil {
+		return err
+	}
+
+	results, err := generateSchemas(generator, chartSearchRoot, filter)
+	if err != nil {
+		return err
 	}
 
-	chartNameToResult := make(map[string]*schema.Result)
 	foundErrors := false
+	foundOutdated := false
 
 	// process results
 	for _, result := range results {
 		// Error handling
 		if len(result.Errors) > 0 {
 			foundErrors = true
-			if result.Chart != nil {
-				log.Errorf(
-					"Found %d errors while processing the chart %s (%s)",
-					len(result.Errors),
-					result.Chart.Name,
-					result.ChartPath,
-				)
-			} else {
-				log.Errorf("Found %d errors while processing the chart %s", len(result.Errors), result.ChartPath)
-			}
-			for _, err := range result.Errors {
-				log.Error(err)
-			}
+			logResultErrors(result)
 			continue
 		}
 
 		log.Debugf("Processing result for chart: %s (%s)", result.Chart.Name, result.ChartPath)
-		if !noDeps {
-			// Patch condition into schema if needed
-			if patch, ok := conditionsToPatch[result.Chart.Name]; ok {
-				schemaToPatch := &result.Schema
-				lastIndex := len(patch) - 1
-				for i, key := range patch {
-					if alreadyPresentSchema, ok := schemaToPatch.Properties[key]; !ok {
-						log.Debugf(
-							"Patching conditional field \"%s\" into schema of chart %s",
-							key,
-							result.Chart.Name,
-						)
-						if i == lastIndex {
-							schemaToPatch.Properties[key] = &schema.Schema{
-								Type:        []string{"boolean"},
-								Title:       key,
-								Description: "Conditional property used in parent chart",
-							}
-						} else {
-							schemaToPatch.Properties[key] = &schema.Schema{Type: []string{"object"}, Title: key}
-							schemaToPatch = schemaToPatch.Properties[key]
-						}
-					} else {
-						schemaToPatch = alreadyPresentSchema
-					}
-				}
-			}
 
-			for _, dep := range result.Chart.Dependencies {
-				if dep.Name != "" {
-					if dependencyResult, ok := chartNameToResult[dep.Name]; ok {
-						log.Debugf(
-							"Found chart of dependency %s (%s)",
-							dependencyResult.Chart.Name,
-							dependencyResult.ChartPath,
-						)
-						depSchema := schema.Schema{
-							Type:        []string{"object"},
-							Title:       dep.Name,
-							Description: dependencyResult.Chart.Description,
-							Properties:  dependencyResult.Schema.Properties,
-						}
-						// you don't NEED to overwrite the values
-						// so every required check will be disabled
-						depSchema.DisableRequiredProperties()
-
-						if dep.Alias != "" {
-							result.Schema.Properties[dep.Alias] = &depSchema
-						} else {
-							result.Schema.Properties[dep.Name] = &depSchema
-						}
-
-					} else {
-						log.Warnf("Dependency (%s->%s) specified but no schema found. If you want to create jsonschemas for external dependencies, you need to run helm dependency build & untar the charts.", result.Chart.Name, dep.Name)
-					}
-				} else {
-					log.Warnf("Dependency without name found (checkout %s).", result.ChartPath)
-				}
-			}
-			chartNameToResult[result.Chart.Name] = result
+		// packaged charts are only read to merge them into their parents
+		if result.Archive {
+			continue
+		}
+
+		chartBasePath := filepath.Dir(result.ChartPath)
+		chartConfig, err := cfg.ForChart(chartBasePath)
+		if err != nil {
+			log.Error(err)
+			foundErrors = true
+			continue
 		}
+		outFile := stringOption("output-file", chartConfig.OutputFile)
 
 		// Print to stdout or write to file
-		jsonStr, err := result.Schema.ToJson()
+		toJson := result.Schema.ToJson
+		if orderedOutput {
+			toJson = result.Schema.ToOrderedJson
+		}
+		jsonStr, err := toJson()
 		if err != nil {
 			log.Error(err)
 			continue
@@ -244,7 +412,22 @@ loop:
 			jsonStr = append(jsonStr, '\n')
 		}
 
-		if dryRun {
+		if check {
+			schemaPath := filepath.Join(chartBasePath, outFile)
+			diff, err := diffSchema(schemaPath, jsonStr)
+			if err != nil {
+				log.Error(err)
+				foundErrors = true
+				continue
+			}
+			if diff != "" {
+				log.Errorf("jsonschema of %s chart (%s) is out of date", result.Chart.Name, schemaPath)
+				fmt.Print(diff)
+				foundOutdated = true
+			} else {
+				log.Debugf("jsonschema of %s chart (%s) is up to date", result.Chart.Name, schemaPath)
+			}
+		} else if dryRun {
 			log.Infof("Printing jsonschema for %s chart (%s)", result.Chart.Name, result.ChartPath)
 			if appendNewline {
 				fmt.Printf("%s", jsonStr)
@@ -252,9 +435,9 @@ loop:
 				fmt.Printf("%s\n", jsonStr)
 			}
 		} else {
-			chartBasePath := filepath.Dir(result.ChartPath)
 			if err := os.WriteFile(filepath.Join(chartBasePath, outFile), jsonStr, 0644); err != nil {
-				errs <- err
+				log.Error(err)
+				foundErrors = true
 				continue
 			}
 		}
@@ -262,11 +445,14 @@ loop:
 	if foundErrors {
 		return errors.New("some errors were found")
 	}
+	if foundOutdated {
+		return errors.New("some jsonschemas are out of date, run helm-schema to update them")
+	}
 	return nil
 }
 
 func main() {
-	command, err := newCommand(exec)
+	command, err := newCommand(exec, validate, generateDocs)
 	if err != nil {
 		log.Errorf("Failed to create the CLI commander: %s", err)
 		os.Exit(1)
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/winterRel/helm-schema/pkg/util"
+)
+
+func TestCheckDoesNotWriteFiles(t *testing.T) {
+	chartDir := t.TempDir()
+	values := "replicas: 1\n"
+	if err := os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: app\nversion: 1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(values), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	command, err := newCommand(exec, validate, generateDocs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command.SetArgs([]string{"--check", "--add-schema-reference", "--chart-search-root", chartDir})
+	if err := command.Execute(); err == nil {
+		t.Error("Expected the check to fail for the missing jsonschema, but it succeeded")
+	}
+
+	content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != values {
+		t.Errorf("Expected values.yaml to be unchanged, but got:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, "values.schema.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no jsonschema to be written, but got: %v", err)
+	}
+}
+
+func TestSearchFilesKeepsIgnoredDependencies(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":                  "*.tgz\ncharts/\nvendor/\n",
+		"app/Chart.yaml":              "name: app\nversion: 1.0.0\n",
+		"app/charts/dep-1.0.0.tgz":    "",
+		"app/charts/sub/Chart.yaml":   "name: sub\nversion: 1.0.0\n",
+		"vendor/other/Chart.yaml":     "name: other\nversion: 1.0.0\n",
+		"vendor/charts/dep-1.0.0.tgz": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := &fileFilter{root: root, exclude: &util.PathMatcher{}, ignorer: util.NewIgnorer(root, ".gitignore", ".helmignore")}
+	queue := make(chan string)
+	errs := make(chan error, 10)
+	go searchFiles(root, "Chart.yaml", filter, queue, errs)
+
+	var found []string
+	for path := range queue {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = append(found, filepath.ToSlash(rel))
+	}
+	sort.Strings(found)
+
+	expected := []string{"app/Chart.yaml", "app/charts/dep-1.0.0.tgz", "app/charts/sub/Chart.yaml"}
+	if strings.Join(found, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the charts %v, but got %v", expected, found)
+	}
+}
+
+func TestUnknownArgumentsFail(t *testing.T) {
+	chartDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: app\nversion: 1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("replicas: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	command, err := newCommand(exec, validate, generateDocs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command.SetArgs([]string{"valdate", "--chart-search-root", chartDir})
+	if err := command.Execute(); err == nil || !strings.Contains(err.Error(), `"valdate"`) {
+		t.Errorf("Expected an error for the mistyped subcommand, but got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, "values.schema.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no jsonschema to be written, but got: %v", err)
+	}
+
+	// the changed files passed by pre-commit are accepted
+	command.SetArgs([]string{"--chart-search-root", chartDir, filepath.Join(chartDir, "values.yaml")})
+	if err := command.Execute(); err != nil {
+		t.Errorf("Wasn't expecting an error for existing files, but got: %v", err)
+	}
+}
+
+func TestDocsValueFilesAndExclude(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app/Chart.yaml":       "name: app\nversion: 1.0.0\n",
+		"app/values-prod.yaml": "# -- number of pods\nreplicas: 1\n",
+		"skip/Chart.yaml":      "name: skip\nversion: 1.0.0\n",
+		"skip/values.yaml":     "replicas: 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	command, err := newCommand(exec, validate, generateDocs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command.SetArgs([]string{"docs", "-f", "values-prod.yaml", "--exclude", "skip", "--chart-search-root", root})
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, "app", "VALUES.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "replicas") {
+		t.Errorf("Expected the docs of values-prod.yaml, but got:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(root, "skip", "VALUES.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected no docs for the excluded chart, but got: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/winterRel/helm-schema/pkg/config"
+	"github.com/winterRel/helm-schema/pkg/schema"
+)
+
+// loadChartSchema reads the jsonschema given with --schema or generates it for the chart in chartDir
+func loadChartSchema(chartDir string) (*jsonschema.Schema, error) {
+	if schemaFile := viper.GetString("schema"); schemaFile != "" {
+		log.Debugf("Using jsonschema %s", schemaFile)
+		content, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		compiled, err := schema.CompileJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not compile %s: %w", schemaFile, err)
+		}
+		return compiled, nil
+	}
+
+	cfg, err := config.Load(viper.GetString("chart-search-root"))
+	if err != nil {
+		return nil, err
+	}
+
+	generator, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := generateSchemas(generator, chartDir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	chartPath := filepath.Join(chartDir, "Chart.yaml")
+	for _, result := range results {
+		if result.Archive || filepath.Clean(result.ChartPath) != chartPath {
+			continue
+		}
+		if len(result.Errors) > 0 {
+			for _, err := range result.Errors {
+				log.Error(err)
+			}
+			return nil, fmt.Errorf("could not generate the jsonschema of %s", chartDir)
+		}
+		log.Debugf("Generated jsonschema for %s chart (%s)", result.Chart.Name, result.ChartPath)
+		return result.Schema.Compile()
+	}
+	return nil, fmt.Errorf("no chart found in %s", chartDir)
+}
+
+func validate(cmd *cobra.Command, args []string) error {
+	configureLogging()
+
+	chartDir := "."
+	if len(args) > 0 {
+		chartDir = args[0]
+	}
+	chartDir = filepath.Clean(chartDir)
+
+	var valuesFiles []string
+	if err := viper.UnmarshalKey("values", &valuesFiles); err != nil {
+		return err
+	}
+	if len(valuesFiles) == 0 {
+		return errors.New("no values files given, use -f to specify them")
+	}
+
+	compiled, err := loadChartSchema(chartDir)
+	if err != nil {
+		return err
+	}
+
+	foundViolations := false
+	for _, valuesFile := range valuesFiles {
+		content, err := os.ReadFile(valuesFile)
+		if err != nil {
+			return err
+		}
+
+		violations, err := schema.ValidateValues(compiled, valuesFile, content)
+		if err != nil {
+			return fmt.Errorf("could not validate %s: %w", valuesFile, err)
+		}
+		if len(violations) == 0 {
+			log.Infof("%s is valid", valuesFile)
+			continue
+		}
+
+		foundViolations = true
+		log.Errorf("Found %d violations in %s", len(violations), valuesFile)
+		for _, violation := range violations {
+			log.Error(violation)
+		}
+	}
+
+	if foundViolations {
+		return errors.New("some values files don't match the jsonschema")
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package chart
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/winterRel/helm-schema/pkg/util"
@@ -13,8 +14,39 @@ type Dependency struct {
 	Condition  string `yaml:"condition,omitempty"`
 	Repository string `yaml:"repository,omitempty"`
 	Alias      string `yaml:"alias,omitempty"`
-	// Tags         []string `yaml:"tags,omitempty"`
-	// ImportValues []string `yaml:"import-values,omitempty"`
+	// Tags can be used to enable or disable the dependency in the top level tags values
+	Tags []string `yaml:"tags,omitempty"`
+	// ImportValues are the values of the dependency imported into the values of the parent
+	ImportValues []ImportValue `yaml:"import-values,omitempty"`
+}
+
+// ImportValue maps a value of the dependency (Child) to a value of the parent chart (Parent).
+// Both are dot separated paths, the parent path "." is the root of the parent values.
+// https://helm.sh/docs/topics/charts/#importing-child-values-via-dependencies
+type ImportValue struct {
+	Child  string `yaml:"child"`
+	Parent string `yaml:"parent"`
+}
+
+// UnmarshalYAML reads both forms of import-values entries. The string form imports
+// the exported value with that name into the root of the parent values, like helm does.
+func (i *ImportValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		i.Child = "exports." + node.Value
+		i.Parent = "."
+		return nil
+	}
+
+	type importValue ImportValue
+	var value importValue
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	if value.Child == "" || value.Parent == "" {
+		return fmt.Errorf("line %d: import-values entries need a child and a parent", node.Line)
+	}
+	*i = ImportValue(value)
+	return nil
 }
 
 // Maintainer describes a Chart maintainer.
@@ -67,6 +99,15 @@ type ChartFile struct {
 	Type string `yaml:"type,omitempty"`
 }
 
+// APIVersionV1 is the apiVersion of helm 2 charts, which define their dependencies in requirements.yaml
+const APIVersionV1 = "v1"
+
+// Lock is the content of a lock file (Chart.lock or requirements.lock)
+type Lock struct {
+	// Dependencies contain the resolved versions of the dependencies
+	Dependencies []*Dependency `yaml:"dependencies"`
+}
+
 // ReadChart parses the given yaml into a ChartFile struct
 func ReadChart(reader io.Reader) (ChartFile, error) {
 	var chart ChartFile
@@ -82,3 +123,45 @@ func ReadChart(reader io.Reader) (ChartFile, error) {
 	}
 	return chart, nil
 }
+
+// ReadRequirements parses the dependencies of a requirements.yaml file
+func ReadRequirements(reader io.Reader) ([]*Dependency, error) {
+	content, err := util.ReadFileAndFixNewline(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var requirements struct {
+		Dependencies []*Dependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(content, &requirements); err != nil {
+		return nil, err
+	}
+	return requirements.Dependencies, nil
+}
+
+// ReadLock parses the given yaml into a Lock struct
+func ReadLock(reader io.Reader) (Lock, error) {
+	var lock Lock
+
+	content, err := util.ReadFileAndFixNewline(reader)
+	if err != nil {
+		return lock, err
+	}
+
+	err = yaml.Unmarshal(content, &lock)
+	return lock, err
+}
+
+// ApplyLock replaces the version constraints of the dependencies with the versions resolved in the
+// lock. Dependencies are matched by name and repository, like helm does.
+func (c *ChartFile) ApplyLock(lock Lock) {
+	for _, dep := range c.Dependencies {
+		for _, locked := range lock.Dependencies {
+			if locked.Name == dep.Name && locked.Repository == dep.Repository && locked.Version != "" {
+				dep.Version = locked.Version
+				break
+			}
+		}
+	}
+}
@@ -30,3 +30,89 @@ func TestReadChartFile(t *testing.T) {
 		t.Errorf("Expected Dependency name was test, but got %v", c.Dependencies[0].Name)
 	}
 }
+
+func TestReadChartFileDependencyOptions(t *testing.T) {
+	data := []byte(`
+name: test
+dependencies:
+  - name: sub
+    tags: [backend, storage]
+    import-values:
+      - data
+      - child: default.data
+        parent: myimports
+`)
+	c, err := ReadChart(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error while reading test data: %v", err)
+	}
+	dep := c.Dependencies[0]
+	if len(dep.Tags) != 2 || dep.Tags[0] != "backend" || dep.Tags[1] != "storage" {
+		t.Errorf("Expected the tags backend and storage, but got %v", dep.Tags)
+	}
+	expected := []ImportValue{
+		{Child: "exports.data", Parent: "."},
+		{Child: "default.data", Parent: "myimports"},
+	}
+	if len(dep.ImportValues) != len(expected) {
+		t.Fatalf("Expected %d import values, but got %v", len(expected), dep.ImportValues)
+	}
+	for i, importValue := range expected {
+		if dep.ImportValues[i] != importValue {
+			t.Errorf("Expected import value %v, but got %v", importValue, dep.ImportValues[i])
+		}
+	}
+
+	_, err = ReadChart(bytes.NewReader([]byte(`
+dependencies:
+  - name: sub
+    import-values:
+      - child: default.data
+`)))
+	if err == nil {
+		t.Error("Expected an error for an import value without parent")
+	}
+}
+
+func TestReadRequirementsAndLock(t *testing.T) {
+	dependencies, err := ReadRequirements(bytes.NewReader([]byte(`
+dependencies:
+  - name: sub
+    version: ~0.1.0
+    repository: https://charts.example.com
+    condition: sub.enabled
+  - name: other
+    version: ^1.0.0
+    repository: https://other.example.com
+`)))
+	if err != nil {
+		t.Fatalf("Error while reading requirements: %v", err)
+	}
+	if len(dependencies) != 2 || dependencies[0].Condition != "sub.enabled" {
+		t.Fatalf("Expected two dependencies with the condition of sub, but got %v", dependencies)
+	}
+
+	lock, err := ReadLock(bytes.NewReader([]byte(`
+dependencies:
+  - name: sub
+    version: 0.1.2
+    repository: https://charts.example.com
+  - name: other
+    version: 1.2.0
+    repository: https://somewhere.else
+digest: sha256:0000
+generated: "2020-01-01T00:00:00Z"
+`)))
+	if err != nil {
+		t.Fatalf("Error while reading lock: %v", err)
+	}
+
+	c := ChartFile{Dependencies: dependencies}
+	c.ApplyLock(lock)
+	if c.Dependencies[0].Version != "0.1.2" {
+		t.Errorf("Expected the locked version 0.1.2, but got %v", c.Dependencies[0].Version)
+	}
+	if c.Dependencies[1].Version != "^1.0.0" {
+		t.Errorf("Expected the version of a dependency from another repository to be kept, but got %v", c.Dependencies[1].Version)
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file searched in the chart search root and in every chart directory
+const FileName = ".helm-schema.yaml"
+
+// ChartConfig contains the options which can be configured per chart.
+// Options which aren't set (nil) are taken from the next less specific level.
+type ChartConfig struct {
+	ValueFiles                []string `yaml:"value-files"`
+	OutputFile                *string  `yaml:"output-file"`
+	SkipAutoGeneration        []string `yaml:"skip-auto-generation"`
+	HelmDocsCompatibilityMode *bool    `yaml:"helm-docs-compatibility-mode"`
+	DontStripHelmDocsPrefix   *bool    `yaml:"dont-strip-helm-docs-prefix"`
+	NoDependencies            *bool    `yaml:"no-dependencies"`
+	MergeValueFiles           *bool    `yaml:"merge-value-files"`
+	DependencyRefs            *bool    `yaml:"dependency-refs"`
+	WarnInvalidDefaults       *bool    `yaml:"warn-invalid-defaults"`
+	NoSelfCheck               *bool    `yaml:"no-self-check"`
+}
+
+// Config is the content of a config file
+type Config struct {
+	// ChartConfig contains the defaults for all charts
+	ChartConfig `yaml:",inline"`
+	// Charts contains overrides keyed by chart directory (relative to the config file)
+	Charts map[string]ChartConfig `yaml:"charts"`
+
+	// dir is the directory containing the config file
+	dir string
+}
+
+// Read parses a config file read from reader, relative chart directories are resolved against dir
+func Read(reader io.Reader, dir string) (*Config, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{dir: dir}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Load reads the config file in dir, an empty config is returned if there is none
+func Load(dir string) (*Config, error) {
+	file, err := os.Open(filepath.Join(dir, FileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Config{dir: dir}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	config, err := Read(file, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", file.Name(), err)
+	}
+	return config, nil
+}
+
+// ForChart returns the config of the chart in chartDir. The defaults of the config are overridden
+// by its charts entry for chartDir, which is overridden by a config file in chartDir itself.
+func (c *Config) ForChart(chartDir string) (ChartConfig, error) {
+	result := c.ChartConfig
+
+	if relDir, err := filepath.Rel(c.dir, chartDir); err == nil {
+		for dir, override := range c.Charts {
+			if filepath.Clean(dir) == relDir {
+				result = result.Merge(override)
+			}
+		}
+	}
+
+	if filepath.Clean(chartDir) == filepath.Clean(c.dir) {
+		return result, nil
+	}
+
+	chartConfig, err := Load(chartDir)
+	if err != nil {
+		return result, err
+	}
+	if len(chartConfig.Charts) > 0 {
+		return result, fmt.Errorf("%s: charts can only be configured in the config file of the chart search root", filepath.Join(chartDir, FileName))
+	}
+	return result.Merge(chartConfig.ChartConfig), nil
+}
+
+// Merge returns a copy of c with all options set in override replaced
+func (c ChartConfig) Merge(override ChartConfig) ChartConfig {
+	if override.ValueFiles != nil {
+		c.ValueFiles = override.ValueFiles
+	}
+	if override.OutputFile != nil {
+		c.OutputFile = override.OutputFile
+	}
+	if override.SkipAutoGeneration != nil {
+		c.SkipAutoGeneration = override.SkipAutoGeneration
+	}
+	if override.HelmDocsCompatibilityMode != nil {
+		c.HelmDocsCompatibilityMode = override.HelmDocsCompatibilityMode
+	}
+	if override.DontStripHelmDocsPrefix != nil {
+		c.DontStripHelmDocsPrefix = override.DontStripHelmDocsPrefix
+	}
+	if override.NoDependencies != nil {
+		c.NoDependencies = override.NoDependencies
+	}
+	if override.MergeValueFiles != nil {
+		c.MergeValueFiles = override.MergeValueFiles
+	}
+	if override.DependencyRefs != nil {
+		c.DependencyRefs = override.DependencyRefs
+	}
+	if override.WarnInvalidDefaults != nil {
+		c.WarnInvalidDefaults = override.WarnInvalidDefaults
+	}
+	if override.NoSelfCheck != nil {
+		c.NoSelfCheck = override.NoSelfCheck
+	}
+	return c
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestForChart(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(path, content string) {
+		assert.Equal(t, os.MkdirAll(filepath.Dir(path), 0755), nil)
+		assert.Equal(t, os.WriteFile(path, []byte(content), 0644), nil)
+	}
+
+	writeFile(filepath.Join(root, FileName), `
+value-files: [values.yaml, values.yml]
+helm-docs-compatibility-mode: true
+charts:
+  charts/b:
+    output-file: schema.json
+    no-dependencies: true
+`)
+	writeFile(filepath.Join(root, "charts", "c", FileName), `
+helm-docs-compatibility-mode: false
+skip-auto-generation: [required]
+`)
+
+	config, err := Load(root)
+	assert.Equal(t, err, nil)
+
+	a, err := config.ForChart(filepath.Join(root, "charts", "a"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, a.ValueFiles, []string{"values.yaml", "values.yml"})
+	assert.Equal(t, *a.HelmDocsCompatibilityMode, true)
+	assert.Equal(t, a.OutputFile, (*string)(nil))
+	assert.Equal(t, a.NoDependencies, (*bool)(nil))
+
+	b, err := config.ForChart(filepath.Join(root, "charts", "b"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *b.OutputFile, "schema.json")
+	assert.Equal(t, *b.NoDependencies, true)
+	assert.Equal(t, *b.HelmDocsCompatibilityMode, true)
+
+	c, err := config.ForChart(filepath.Join(root, "charts", "c"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *c.HelmDocsCompatibilityMode, false)
+	assert.Equal(t, c.SkipAutoGeneration, []string{"required"})
+	assert.Equal(t, c.ValueFiles, []string{"values.yaml", "values.yml"})
+
+	writeFile(filepath.Join(root, "charts", "d", FileName), "charts:\n  e: {}\n")
+	_, err = config.ForChart(filepath.Join(root, "charts", "d"))
+	assert.Equal(t, err != nil, true)
+
+	writeFile(filepath.Join(root, "charts", "f", FileName), "unknown: true\n")
+	_, err = config.ForChart(filepath.Join(root, "charts", "f"))
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,202 @@
+package docs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/winterRel/helm-schema/pkg/chart"
+	"github.com/winterRel/helm-schema/pkg/schema"
+)
+
+// DefaultTemplate is used if no custom template is given
+const DefaultTemplate = `# {{ .Chart.Name }}
+{{ if .Chart.Description }}
+{{ .Chart.Description }}
+{{ end }}
+## Values
+
+| Key | Type | Default | Description | Required |
+|-----|------|---------|-------------|----------|
+{{- range .Rows }}{{ $description := .Description }}
+| {{ escape .Key }} | {{ escape .Type }} | {{ if .Default }}` + "`{{ escape .Default }}`" + `{{ end }} | {{ escape .Description }}{{ range $i, $constraint := .Constraints }}{{ if or $i $description }}<br>{{ end }}{{ escape $constraint }}{{ end }} | {{ if .Required }}yes{{ else }}no{{ end }} |
+{{- end }}
+`
+
+// Row describes a single key of the values file
+type Row struct {
+	// Key is the dot separated path of the key (e.g. image.tag)
+	Key         string
+	Type        string
+	Default     string
+	Description string
+	// Constraints contains the validation keywords, like "minimum: 0"
+	Constraints []string
+	Required    bool
+}
+
+// Data is passed to the template
+type Data struct {
+	Chart *chart.ChartFile
+	Rows  []Row
+}
+
+// Rows walks the schema and returns a row for every key. Objects with
+// properties aren't listed themselves, but their properties are.
+func Rows(s *schema.Schema) []Row {
+	return appendRows(nil, "", s)
+}
+
+func appendRows(rows []Row, keyPath string, s *schema.Schema) []Row {
+	for _, name := range s.PropertyNames() {
+		property := s.Properties[name]
+		key := name
+		if keyPath != "" {
+			key = keyPath + "." + name
+		}
+
+		if len(property.Properties) > 0 {
+			rows = appendRows(rows, key, property)
+			continue
+		}
+		// e.g. the global key added for helm lint isn't part of the values
+		if property.Generated() {
+			continue
+		}
+
+		row := Row{
+			Key:         key,
+			Type:        strings.Join(property.Type, ", "),
+			Description: strings.Join(strings.Fields(property.Description), " "),
+			Constraints: constraints(property),
+			Required:    schema.Contains(s.Required.Strings, name),
+		}
+		if row.Type == "" && property.Ref != "" {
+			row.Type = property.Ref
+		}
+		if property.Default != nil {
+			if defaultValue, err := json.Marshal(property.Default); err == nil {
+				row.Default = string(defaultValue)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// constraints returns the validation keywords of the schema in a human readable form
+func constraints(s *schema.Schema) []string {
+	var result []string
+	add := func(name string, value interface{}) {
+		result = append(result, fmt.Sprintf("%s: %v", name, value))
+	}
+
+	if len(s.Enum) > 0 {
+		var values []string
+		for _, value := range s.Enum {
+			values = append(values, formatValue(value))
+		}
+		add("enum", strings.Join(values, ", "))
+	}
+	if s.Const != nil {
+		add("const", s.Const)
+	}
+	if s.Format != "" {
+		add("format", s.Format)
+	}
+	if s.Pattern != "" {
+		add("pattern", s.Pattern)
+	}
+	for _, keyword := range []struct {
+		name  string
+		value *schema.Number
+	}{
+		{"minimum", s.Minimum},
+		{"exclusiveMinimum", s.ExclusiveMinimum},
+		{"maximum", s.Maximum},
+		{"exclusiveMaximum", s.ExclusiveMaximum},
+		{"multipleOf", s.MultipleOf},
+	} {
+		if keyword.value != nil {
+			add(keyword.name, *keyword.value)
+		}
+	}
+	for _, keyword := range []struct {
+		name  string
+		value *int
+	}{
+		{"minLength", s.MinLength},
+		{"maxLength", s.MaxLength},
+		{"minItems", s.MinItems},
+		{"maxItems", s.MaxItems},
+		{"minProperties", s.MinProperties},
+		{"maxProperties", s.MaxProperties},
+	} {
+		if keyword.value != nil {
+			add(keyword.name, *keyword.value)
+		}
+	}
+	if s.UniqueItems {
+		result = append(result, "uniqueItems")
+	}
+	if s.Deprecated {
+		result = append(result, "deprecated")
+	}
+	return result
+}
+
+// formatValue returns strings as they are and other values as json
+func formatValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	if jsonValue, err := json.Marshal(value); err == nil {
+		return string(jsonValue)
+	}
+	return fmt.Sprint(value)
+}
+
+// StartMarker and EndMarker enclose the generated documentation inside of an existing file
+const (
+	StartMarker = "<!-- helm-schema-docs:start -->"
+	EndMarker   = "<!-- helm-schema-docs:end -->"
+)
+
+// Insert replaces the text between the markers of existing with the rendered documentation.
+// It reports false if existing doesn't contain both markers.
+func Insert(existing, rendered []byte) ([]byte, bool) {
+	start := bytes.Index(existing, []byte(StartMarker))
+	if start < 0 {
+		return nil, false
+	}
+	start += len(StartMarker)
+	end := bytes.Index(existing[start:], []byte(EndMarker))
+	if end < 0 {
+		return nil, false
+	}
+	end += start
+
+	var result bytes.Buffer
+	result.Write(existing[:start])
+	result.WriteString("\n")
+	result.Write(rendered)
+	result.Write(existing[end:])
+	return result.Bytes(), true
+}
+
+// escape makes the text usable inside of a markdown table cell
+func escape(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// Render writes the documentation of the chart rendered with the go template tmpl
+func Render(w io.Writer, tmpl string, chart *chart.ChartFile, s *schema.Schema) error {
+	t, err := template.New("docs").Funcs(template.FuncMap{"escape": escape}).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, Data{Chart: chart, Rows: Rows(s)})
+}
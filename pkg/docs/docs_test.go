@@ -0,0 +1,88 @@
+package docs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/winterRel/helm-schema/pkg/chart"
+	"github.com/winterRel/helm-schema/pkg/schema"
+)
+
+func TestRender(t *testing.T) {
+	generator := schema.NewGenerator(schema.DefaultGeneratorOptions())
+	valuesSchema, err := generator.GenerateFromValues(strings.NewReader(`
+# @schema
+# enum: [a, b]
+# @schema
+# -- the mode
+mode: a
+image:
+  # @schema
+  # required: false
+  # pattern: ^v[0-9]+|latest$
+  # @schema
+  tag: latest
+  # @schema
+  # type: integer
+  # minimum: 1
+  # maximum: 10
+  # @schema
+  replicas: 1
+`))
+	if err != nil {
+		t.Fatalf("Error while generating schema: %v", err)
+	}
+
+	var rendered bytes.Buffer
+	err = Render(&rendered, DefaultTemplate, &chart.ChartFile{Name: "app", Description: "My app"}, valuesSchema)
+	if err != nil {
+		t.Fatalf("Error while rendering docs: %v", err)
+	}
+
+	assert.Equal(t, rendered.String(), "# app\n"+
+		"\n"+
+		"My app\n"+
+		"\n"+
+		"## Values\n"+
+		"\n"+
+		"| Key | Type | Default | Description | Required |\n"+
+		"|-----|------|---------|-------------|----------|\n"+
+		"| mode |  | `\"a\"` | the mode<br>enum: a, b | no |\n"+
+		"| image.tag |  | `\"latest\"` | pattern: ^v[0-9]+\\|latest$ | no |\n"+
+		"| image.replicas | integer | `1` | minimum: 1<br>maximum: 10 | no |\n")
+}
+
+func TestInsert(t *testing.T) {
+	existing := "# app\n\nHand written.\n\n" + StartMarker + "\nold table\n" + EndMarker + "\n\n## License\n"
+	result, ok := Insert([]byte(existing), []byte("new table\n"))
+	if !ok {
+		t.Fatal("Expected the markers to be found")
+	}
+	assert.Equal(t, string(result), "# app\n\nHand written.\n\n"+StartMarker+"\nnew table\n"+EndMarker+"\n\n## License\n")
+
+	if _, ok := Insert([]byte("# app\n"+StartMarker+"\n"), []byte("new table\n")); ok {
+		t.Error("Expected no insertion without an end marker")
+	}
+	if _, ok := Insert(nil, []byte("new table\n")); ok {
+		t.Error("Expected no insertion into a missing file")
+	}
+}
+
+func TestRowsGlobalFromValues(t *testing.T) {
+	generator := schema.NewGenerator(schema.DefaultGeneratorOptions())
+	valuesSchema, err := generator.GenerateFromValues(strings.NewReader(`
+# -- shared by all charts
+global: {}
+`))
+	if err != nil {
+		t.Fatalf("Error while generating schema: %v", err)
+	}
+
+	rows := Rows(valuesSchema)
+	if len(rows) != 1 || rows[0].Key != "global" || rows[0].Description != "shared by all charts" {
+		t.Errorf("Expected a row for the global key of the values file, but got %+v", rows)
+	}
+}
@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// checkDefaults validates the default and the examples of the schema and all its subschemas against
+// the generated jsonschema. The whole schema is compiled, so references to other parts of it are resolved.
+// Mismatches are returned as *ValuesError pointing to the key in the values file the schema belongs to.
+func checkDefaults(valuesPath string, values *yaml.Node, s *Schema) []error {
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		return []error{&ValuesError{ValuesPath: valuesPath, Err: err}}
+	}
+	c := jsonschema.NewCompiler()
+	c.Draft = draftFromURI(s.Schema).compilerDraft()
+	if err := c.AddResource("schema.json", bytes.NewReader(jsonStr)); err != nil {
+		return []error{&ValuesError{ValuesPath: valuesPath, Err: err}}
+	}
+
+	var errs []error
+	// propertyPointer is the pointer of the nearest property, keyPath and keyNode its key in the values
+	// and valueNode its value, if the values contain it
+	var walk func(s *Schema, schemaPointer, propertyPointer string, keyPath []string, keyNode, valueNode *yaml.Node)
+	walk = func(s *Schema, schemaPointer, propertyPointer string, keyPath []string, keyNode, valueNode *yaml.Node) {
+		if err := checkDefaultsOf(c, s, schemaPointer); err != nil {
+			if schemaPointer != propertyPointer {
+				err = fmt.Errorf("%s: %w", strings.TrimPrefix(schemaPointer, propertyPointer+"/"), err)
+			}
+			valuesErr := &ValuesError{ValuesPath: valuesPath, KeyPath: strings.Join(keyPath, "."), Err: err}
+			if keyNode != nil {
+				valuesErr.Line = keyNode.Line
+				valuesErr.Column = keyNode.Column
+			}
+			errs = append(errs, valuesErr)
+		}
+
+		// the keys of the values are indexed once, looking up every property on its own is quadratic
+		var entries map[string][2]*yaml.Node
+		if len(s.Properties) > 0 {
+			entries = mappingEntries(valueNode)
+		}
+		s.eachSubschema(func(keyword, key string, subSchema *Schema) {
+			subSchemaPointer := schemaPointer + "/" + keyword
+			if key != "" {
+				subSchemaPointer += "/" + escapePointerToken(key)
+			}
+			if keyword != "properties" {
+				walk(subSchema, subSchemaPointer, propertyPointer, keyPath, keyNode, nil)
+				return
+			}
+			propertyPath := append(keyPath[:len(keyPath):len(keyPath)], key)
+			entry, ok := entries[key]
+			if !ok {
+				// e.g. properties of subschemas, which aren't part of the values
+				entry[0] = keyNode
+			}
+			walk(subSchema, subSchemaPointer, subSchemaPointer, propertyPath, entry[0], entry[1])
+		})
+	}
+	root := values
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	walk(s, "", "", nil, nil, root)
+	return errs
+}
+
+// mappingEntries returns the key and value nodes of every key of the mapping node,
+// including the keys merged with <<
+func mappingEntries(node *yaml.Node) map[string][2]*yaml.Node {
+	if node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	content, err := expandMergeKeys(node)
+	if err != nil {
+		content = node.Content
+	}
+	entries := make(map[string][2]*yaml.Node, len(content)/2)
+	for i := 0; i+1 < len(content); i += 2 {
+		if _, ok := entries[content[i].Value]; !ok {
+			entries[content[i].Value] = [2]*yaml.Node{content[i], content[i+1]}
+		}
+	}
+	return entries
+}
+
+// checkDefaultsOf validates the default and the examples of the schema found at pointer
+func checkDefaultsOf(c *jsonschema.Compiler, s *Schema, pointer string) error {
+	if s.Default == nil && len(s.Examples) == 0 {
+		return nil
+	}
+	compiled, err := c.Compile("schema.json#" + pointer)
+	if err != nil {
+		// e.g. references which can't be loaded
+		log.Debugf("Skipping the check of the default of %s: %v", pointer, err)
+		return nil
+	}
+
+	if s.Default != nil {
+		if err := validateValue(compiled, "default", s.Default); err != nil {
+			return err
+		}
+	}
+	for _, example := range s.Examples {
+		if err := validateValue(compiled, "examples", example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapePointerToken escapes the name for the use in a json pointer
+func escapePointerToken(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,484 @@
+package schema
+
+import (
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/winterRel/helm-schema/pkg/chart"
+	"gopkg.in/yaml.v3"
+)
+
+// ResolveDependencies sorts the results topologically and merges the jsonschemas
+// of the dependencies into the jsonschemas of their parent charts. Afterwards the values
+// files of every chart are checked against its final jsonschema, as well as the defaults the
+// parent charts set for their dependencies.
+// Results containing errors are skipped, but kept in the returned slice.
+func (g *Generator) ResolveDependencies(results []*Result) ([]*Result, error) {
+	results, err := g.resolveDependencies(results)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		// the values of packaged charts can't be changed anyway
+		if len(result.Errors) > 0 || result.Archive || len(result.valuesPaths) == 0 {
+			continue
+		}
+		options := g.resultOptions(result)
+		if !options.NoDependencies && result.Chart != nil && len(result.Chart.Dependencies) > 0 {
+			result.Errors = append(result.Errors, checkMergedDefaults(result, options.WarnInvalidDefaults)...)
+			if len(result.Errors) > 0 {
+				continue
+			}
+		}
+		if !options.NoSelfCheck {
+			result.Errors = append(result.Errors, selfCheck(&result.Schema, result.valuesPaths, result.valuesContents)...)
+		}
+	}
+	return results, nil
+}
+
+// checkMergedDefaults checks the defaults of the merged jsonschema, because the values of
+// the parent may not match the keywords of the dependencies
+func checkMergedDefaults(result *Result, warnInvalidDefaults bool) []error {
+	var values yaml.Node
+	if err := yaml.Unmarshal(result.valuesContents[0], &values); err != nil {
+		return []error{&ValuesError{ValuesPath: result.valuesPaths[0], Err: err}}
+	}
+	var errs []error
+	for _, err := range checkDefaults(result.valuesPaths[0], &values, &result.Schema) {
+		if warnInvalidDefaults {
+			log.Warnln(err)
+			continue
+		}
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (g *Generator) resolveDependencies(results []*Result) ([]*Result, error) {
+	withDependencies := make(map[*Result]bool)
+	for _, result := range results {
+		if result.Chart != nil && !g.noDependencies(result) {
+			withDependencies[result] = true
+		}
+	}
+	if len(withDependencies) == 0 {
+		return results, nil
+	}
+
+	// prefer unpacked charts over their packaged versions
+	unpacked := make(map[string]bool)
+	for _, result := range results {
+		if result.Chart != nil && !result.Archive {
+			unpacked[result.Chart.Name+"|"+result.Chart.Version] = true
+		}
+	}
+
+	var sortable, failed []*Result
+	for _, result := range results {
+		if result.Archive && result.Chart != nil && unpacked[result.Chart.Name+"|"+result.Chart.Version] {
+			log.Debugf("Ignoring packaged chart %s, because it is also available unpacked", result.ChartPath)
+			// a stale archive mustn't fail the unpacked chart
+			for _, err := range result.Errors {
+				log.Warnf("Ignoring error of packaged chart %s: %v", result.ChartPath, err)
+			}
+			continue
+		}
+		if result.Chart == nil {
+			failed = append(failed, result)
+		} else {
+			sortable = append(sortable, result)
+		}
+	}
+
+	// sort results with topology sort
+	// Need to resolve the dependencies from deepest level to highest
+	sorted, err := TopoSort(sortable)
+	if err != nil {
+		if _, ok := err.(*CircularError); !ok {
+			return nil, err
+		}
+		log.Warnf("Could not sort results: %s", err)
+	}
+	results = append(sorted, failed...)
+	resolver := newDependencyResolver(sortable)
+
+	// Iterate over deps to find conditions pointing into the values of the dependency, those are
+	// patched into the schema of the dependency chart, because helm passes them to the dependency
+	conditionsToPatch := make(map[*Result][]string)
+	for _, result := range results {
+		if len(result.Errors) > 0 || !withDependencies[result] {
+			continue
+		}
+		for _, dep := range result.Chart.Dependencies {
+			dependencyResult := resolver.resolve(result, dep)
+			if dependencyResult == nil {
+				continue
+			}
+			for _, keys := range conditionPaths(dep.Condition) {
+				if len(keys) > 1 && keys[0] == dependencyKey(dep.Name, dep.Alias) {
+					conditionsToPatch[dependencyResult] = appendMissing(conditionsToPatch[dependencyResult], strings.Join(keys[1:], "."))
+				}
+			}
+		}
+	}
+
+	// results whose dependencies are already merged
+	resolved := make(map[*Result]bool)
+	// resolvedDependency returns the chart used for the dependency, if it's already resolved
+	resolvedDependency := func(result *Result, dep *chart.Dependency) (*Result, bool) {
+		dependencyResult := resolver.resolve(result, dep)
+		return dependencyResult, dependencyResult != nil && resolved[dependencyResult]
+	}
+	// tags used by the dependencies of a chart and the dependencies below
+	tagsOfResult := make(map[*Result][]string)
+
+	// propagateGlobal places global into the subtree of a dependency and into the subtrees of
+	// the dependencies merged into it, as helm passes the globals down to all subcharts.
+	// Subtrees referencing a schema in defs get the globals placed in the referenced schema.
+	var propagateGlobal func(subtree *Schema, result *Result, global *Schema, defs map[string]*Schema)
+	propagateGlobal = func(subtree *Schema, result *Result, global *Schema, defs map[string]*Schema) {
+		if name, ok := defName(subtree.Ref); ok && defs[name] != nil {
+			subtree = defs[name]
+		}
+		if merged := mergeGlobals(global, subtree.Properties["global"]); merged != nil {
+			if subtree.Properties == nil {
+				subtree.Properties = make(map[string]*Schema)
+			}
+			subtree.Properties["global"] = merged
+		}
+		if !withDependencies[result] {
+			return
+		}
+		for _, dep := range result.Chart.Dependencies {
+			depSubtree, ok := subtree.Properties[dependencyKey(dep.Name, dep.Alias)]
+			if dependencyResult, found := resolvedDependency(result, dep); ok && found {
+				propagateGlobal(depSubtree, dependencyResult, subtree.Properties["global"], defs)
+			}
+		}
+	}
+
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			continue
+		}
+
+		log.Debugf("Resolving dependencies of chart: %s (%s)", result.Chart.Name, result.ChartPath)
+
+		// Patch condition into schema if needed
+		for _, path := range conditionsToPatch[result] {
+			patchCondition(&result.Schema, splitValuesPath(path), result.Chart.Name)
+		}
+
+		if !withDependencies[result] {
+			log.Debugf("Not merging dependencies into chart %s (%s)", result.Chart.Name, result.ChartPath)
+			resolved[result] = true
+			continue
+		}
+
+		dependencyRefs := g.resultOptions(result).DependencyRefs
+		parentGlobal := result.Schema.Properties["global"]
+		mergedGlobal := parentGlobal
+		// the other conditions are read from the values of the parent (e.g. global.sub.enabled)
+		for _, dep := range result.Chart.Dependencies {
+			_, found := resolvedDependency(result, dep)
+			for _, keys := range conditionPaths(dep.Condition) {
+				if found && len(keys) > 1 && keys[0] == dependencyKey(dep.Name, dep.Alias) {
+					// already part of the schema of the dependency
+					continue
+				}
+				patchCondition(&result.Schema, keys, result.Chart.Name)
+			}
+		}
+
+		var tags []string
+		for _, dep := range result.Chart.Dependencies {
+			tags = appendMissing(tags, dep.Tags...)
+			if dep.Name != "" {
+				if dependencyResult, ok := resolvedDependency(result, dep); ok {
+					log.Debugf(
+						"Found chart of dependency %s (%s)",
+						dependencyResult.Chart.Name,
+						dependencyResult.ChartPath,
+					)
+					// the schemas referenced by the dependency need to be part of the chart schema
+					for name, def := range dependencyResult.Schema.Defs {
+						if result.Schema.Defs == nil {
+							result.Schema.Defs = make(map[string]*Schema)
+						}
+						if _, ok := result.Schema.Defs[name]; !ok {
+							result.Schema.Defs[name] = def.Clone()
+						}
+					}
+
+					depSchema := Schema{
+						Type:        []string{"object"},
+						Title:       dep.Name,
+						Description: dependencyResult.Chart.Description,
+						Properties:  cloneSchemaMap(dependencyResult.Schema.Properties),
+						// keep the order of the values file of the dependency
+						propertyOrder: dependencyResult.Schema.propertyOrder,
+					}
+					propagateGlobal(&depSchema, dependencyResult, parentGlobal, result.Schema.Defs)
+					// the globals of the dependency can be set in the parent as well
+					mergedGlobal = mergeGlobals(mergedGlobal, dependencyResult.Schema.Properties["global"])
+
+					// you don't NEED to overwrite the values
+					// so every required check will be disabled
+					depSchema.DisableRequiredProperties()
+
+					if result.Schema.Properties == nil {
+						result.Schema.Properties = make(map[string]*Schema)
+					}
+					key := dependencyKey(dep.Name, dep.Alias)
+					// the annotations and values of the parent are applied on top of the dependency
+					overlaid := overlaySchema(&depSchema, result.Schema.Properties[key])
+					// properties added by the parent would be rejected by the referenced definition
+					if dependencyRefs && !widensSchema(&depSchema, overlaid) {
+						// aliases of the same chart share one definition
+						name := dependencyResult.Chart.Name + "-" + dependencyResult.Chart.Version
+						if result.Schema.Defs == nil {
+							result.Schema.Defs = make(map[string]*Schema)
+						}
+						if _, ok := result.Schema.Defs[name]; !ok {
+							result.Schema.Defs[name] = &depSchema
+						}
+						result.Schema.Properties[key] = &Schema{Ref: defRef(name)}
+						// only the changes of the parent are added next to the reference
+						if diff := schemaDiff(&depSchema, overlaid); diff != nil {
+							diff.AllOf = append([]*Schema{{Ref: defRef(name)}}, diff.AllOf...)
+							result.Schema.Properties[key] = diff
+						}
+					} else {
+						result.Schema.Properties[key] = overlaid
+					}
+
+					for _, importValue := range dep.ImportValues {
+						importValues(&result.Schema, dependencyResult, importValue)
+					}
+					// helm reads the tags of all dependencies from the values of the top level chart
+					tags = appendMissing(tags, tagsOfResult[dependencyResult]...)
+				} else if dir, ok := fileDependencyDir(result, dep); ok {
+					log.Warnf("Dependency (%s->%s) specified but no chart %s found in %s or the charts directory.", result.Chart.Name, dep.Name, dep.Name, dir)
+				} else {
+					log.Warnf("Dependency (%s->%s) specified but no schema found. If you want to create jsonschemas for external dependencies, you need to run helm dependency build.", result.Chart.Name, dep.Name)
+				}
+			} else {
+				log.Warnf("Dependency without name found (checkout %s).", result.ChartPath)
+			}
+		}
+		if parentGlobal != nil {
+			result.Schema.Properties["global"] = mergedGlobal
+		}
+		patchTags(&result.Schema, tags)
+		tagsOfResult[result] = tags
+		resolved[result] = true
+	}
+
+	return results, nil
+}
+
+// conditionPaths returns the keys of every path of a dependency condition. Helm
+// uses the first of the comma separated paths which is found in the values.
+func conditionPaths(condition string) [][]string {
+	var paths [][]string
+	for _, path := range strings.Split(condition, ",") {
+		if keys := splitValuesPath(strings.TrimSpace(path)); len(keys) > 0 {
+			paths = append(paths, keys)
+		}
+	}
+	return paths
+}
+
+// patchCondition adds the condition with the given keys as optional boolean to the schema, if it's missing
+func patchCondition(s *Schema, keys []string, chartName string) {
+	schemaToPatch := s
+	lastIndex := len(keys) - 1
+	for i, key := range keys {
+		if alreadyPresentSchema, ok := schemaToPatch.Properties[key]; ok {
+			schemaToPatch = alreadyPresentSchema
+			continue
+		}
+		log.Debugf(
+			"Patching conditional field \"%s\" into schema of chart %s",
+			strings.Join(keys[:i+1], "."),
+			chartName,
+		)
+		if schemaToPatch.Properties == nil {
+			schemaToPatch.Properties = make(map[string]*Schema)
+		}
+		if i == lastIndex {
+			schemaToPatch.Properties[key] = &Schema{
+				Type:        []string{"boolean"},
+				Title:       key,
+				Description: "Conditional property used in parent chart",
+			}
+		} else {
+			schemaToPatch.Properties[key] = &Schema{Type: []string{"object"}, Title: key}
+			schemaToPatch = schemaToPatch.Properties[key]
+		}
+	}
+}
+
+// patchTags adds a boolean property for every tag to the tags object of the schema
+func patchTags(s *Schema, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if s.Properties == nil {
+		s.Properties = make(map[string]*Schema)
+	}
+	tagsSchema, ok := s.Properties["tags"]
+	if !ok {
+		tagsSchema = &Schema{
+			Type:        []string{"object"},
+			Title:       "tags",
+			Description: "Tags to enable or disable the dependencies using them",
+		}
+		s.Properties["tags"] = tagsSchema
+	}
+	if tagsSchema.Properties == nil {
+		tagsSchema.Properties = make(map[string]*Schema)
+	}
+	for _, tag := range tags {
+		if _, ok := tagsSchema.Properties[tag]; ok {
+			continue
+		}
+		log.Debugf("Patching tag \"%s\" into schema", tag)
+		tagsSchema.Properties[tag] = &Schema{
+			Type:        []string{"boolean"},
+			Title:       tag,
+			Description: "Tag used by dependencies",
+		}
+	}
+}
+
+// importValues copies the schema of a value imported from the dependency into the
+// schema of the parent chart, like helm does with the values themselves
+func importValues(parent *Schema, dependency *Result, importValue chart.ImportValue) {
+	imported := schemaAtPath(&dependency.Schema, importValue.Child)
+	if imported == nil {
+		log.Warnf(
+			"Value %s imported by %s not found in the schema of dependency %s",
+			importValue.Child,
+			importValue.Parent,
+			dependency.Chart.Name,
+		)
+		return
+	}
+	imported = imported.Clone()
+	// the imported values are defaults, the parent doesn't need to set them
+	imported.DisableRequiredProperties()
+
+	if parent.Properties == nil {
+		parent.Properties = make(map[string]*Schema)
+	}
+	keys := splitValuesPath(importValue.Parent)
+	if len(keys) == 0 {
+		// the properties of the value are imported into the root of the parent
+		for _, name := range imported.PropertyNames() {
+			parent.Properties[name] = mergeSchemas(parent.Properties[name], imported.Properties[name], false)
+		}
+		return
+	}
+
+	target := parent
+	for _, key := range keys[:len(keys)-1] {
+		if _, ok := target.Properties[key]; !ok {
+			target.Properties[key] = &Schema{Type: []string{"object"}, Title: key}
+		}
+		target = target.Properties[key]
+		if target.Properties == nil {
+			target.Properties = make(map[string]*Schema)
+		}
+	}
+	key := keys[len(keys)-1]
+	target.Properties[key] = mergeSchemas(target.Properties[key], imported, false)
+}
+
+// schemaAtPath returns the schema of the value with the dot separated path
+func schemaAtPath(s *Schema, path string) *Schema {
+	for _, key := range splitValuesPath(path) {
+		if s == nil {
+			return nil
+		}
+		s = s.Properties[key]
+	}
+	return s
+}
+
+// splitValuesPath splits a dot separated values path, the root "." has no keys
+func splitValuesPath(path string) []string {
+	path = strings.Trim(path, ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// appendMissing appends the values which aren't part of the list yet
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// defRef returns the reference of the schema with the name in $defs
+func defRef(name string) string {
+	return "#/$defs/" + name
+}
+
+// defName returns the name of the schema in $defs referenced by ref
+func defName(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, "#/$defs/") {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, "#/$defs/"), true
+}
+
+// dependencyKey returns the key of the dependency values in the values of the parent chart
+func dependencyKey(name, alias string) string {
+	if alias != "" {
+		return alias
+	}
+	return name
+}
+
+// mergeGlobals merges the global schema of a subchart into the one of its parent. The values of
+// the subchart are only defaults, so none of its globals are required, and a parent global
+// without additionalProperties keeps accepting arbitrary globals.
+func mergeGlobals(parent, subchart *Schema) *Schema {
+	if subchart != nil {
+		subchart = subchart.Clone()
+		subchart.DisableRequiredProperties()
+	}
+	if parent == nil {
+		return subchart
+	}
+	merged := mergeSchemas(parent, subchart, false)
+	if parent.AdditionalProperties == nil {
+		merged.AdditionalProperties = nil
+	}
+	return merged
+}
+
+// noDependencies checks if the dependencies shouldn't be merged into the result
+func (g *Generator) noDependencies(result *Result) bool {
+	return g.resultOptions(result).NoDependencies
+}
+
+// resultOptions returns the options used for the chart of the result
+func (g *Generator) resultOptions(result *Result) GeneratorOptions {
+	if result.Archive {
+		return g.options
+	}
+	generator, err := g.forChart(filepath.Dir(result.ChartPath))
+	if err != nil {
+		return g.options
+	}
+	return generator.options
+}
@@ -0,0 +1,576 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/winterRel/helm-schema/pkg/chart"
+)
+
+// newTestResult generates the result of a chart with the given values
+func newTestResult(t *testing.T, chartFile *chart.ChartFile, values string) *Result {
+	t.Helper()
+	schema, err := NewGenerator(DefaultGeneratorOptions()).GenerateFromValues(strings.NewReader(values))
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	return &Result{
+		ChartPath: chartFile.Name + "/Chart.yaml",
+		Chart:     chartFile,
+		Schema:    *schema,
+	}
+}
+
+func TestResolveDependenciesGlobals(t *testing.T) {
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:         "parent",
+		Version:      "1.0.0",
+		Dependencies: []*chart.Dependency{{Name: "sub", Version: "~0.1.0", Alias: "backend"}},
+	}, `
+global:
+  # @schema
+  # required: true
+  # @schema
+  domain: example.com
+`)
+	sub := newTestResult(t, &chart.ChartFile{
+		Name:         "sub",
+		Version:      "0.1.0",
+		Dependencies: []*chart.Dependency{{Name: "leaf", Version: "^2.0.0"}},
+	}, `
+global:
+  image:
+    registry: docker.io
+`)
+	leaf := newTestResult(t, &chart.ChartFile{Name: "leaf", Version: "2.1.0"}, "replicas: 1\n")
+
+	results, err := NewGenerator(DefaultGeneratorOptions()).ResolveDependencies([]*Result{parent, sub, leaf})
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, but got %d", len(results))
+	}
+
+	// the parent accepts the globals of its subcharts, but only requires its own
+	global := parent.Schema.Properties["global"]
+	for _, name := range []string{"domain", "image"} {
+		if _, ok := global.Properties[name]; !ok {
+			t.Errorf("Expected global.%s in the parent schema, but got %v", name, global.PropertyNames())
+		}
+	}
+	if strings.Join(global.Required.Strings, ",") != "domain" {
+		t.Errorf("Expected only global.domain to be required, but got %v", global.Required.Strings)
+	}
+
+	// every dependency subtree gets the merged globals
+	subGlobal := parent.Schema.Properties["backend"].Properties["global"]
+	leafGlobal := parent.Schema.Properties["backend"].Properties["leaf"].Properties["global"]
+	for path, schema := range map[string]*Schema{"backend.global": subGlobal, "backend.leaf.global": leafGlobal} {
+		if schema == nil {
+			t.Errorf("Expected %s in the parent schema", path)
+			continue
+		}
+		for _, name := range []string{"domain", "image"} {
+			if _, ok := schema.Properties[name]; !ok {
+				t.Errorf("Expected %s.%s in the parent schema, but got %v", path, name, schema.PropertyNames())
+			}
+		}
+		if len(schema.Required.Strings) > 0 {
+			t.Errorf("Expected nothing to be required in %s, but got %v", path, schema.Required.Strings)
+		}
+	}
+
+	// the auto generated global of the leaf chart stays open for arbitrary globals
+	if leaf.Schema.Properties["global"].AdditionalProperties != nil {
+		t.Errorf("Expected the global of the leaf chart to allow additional properties")
+	}
+}
+
+func TestResolveDependenciesTagsAndImportValues(t *testing.T) {
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:    "parent",
+		Version: "1.0.0",
+		Dependencies: []*chart.Dependency{{
+			Name:    "sub",
+			Version: "0.1.0",
+			Tags:    []string{"backend"},
+			ImportValues: []chart.ImportValue{
+				{Child: "exports.data", Parent: "."},
+				{Child: "default.port", Parent: "imported.port"},
+			},
+		}},
+	}, "replicas: 1\n")
+	sub := newTestResult(t, &chart.ChartFile{
+		Name:         "sub",
+		Version:      "0.1.0",
+		Dependencies: []*chart.Dependency{{Name: "leaf", Version: "2.0.0", Tags: []string{"storage"}}},
+	}, `
+exports:
+  data:
+    # @schema
+    # pattern: ^[a-z]+$
+    # @schema
+    name: sub
+default:
+  # @schema
+  # type: integer
+  # @schema
+  port: 80
+`)
+	leaf := newTestResult(t, &chart.ChartFile{Name: "leaf", Version: "2.0.0"}, "replicas: 1\n")
+
+	if _, err := NewGenerator(DefaultGeneratorOptions()).ResolveDependencies([]*Result{parent, sub, leaf}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	tags := parent.Schema.Properties["tags"]
+	if tags == nil {
+		t.Fatal("Expected tags in the parent schema")
+	}
+	for _, tag := range []string{"backend", "storage"} {
+		if tagSchema, ok := tags.Properties[tag]; !ok || !tagSchema.Type.Matches("boolean") {
+			t.Errorf("Expected the boolean tag %s, but got %v", tag, tags.PropertyNames())
+		}
+	}
+
+	if name := parent.Schema.Properties["name"]; name == nil || name.Pattern != "^[a-z]+$" {
+		t.Errorf("Expected exports.data.name to be imported into the root, but got %+v", name)
+	}
+	imported := parent.Schema.Properties["imported"]
+	if imported == nil || imported.Properties["port"] == nil || !imported.Properties["port"].Type.Matches("integer") {
+		t.Fatalf("Expected default.port to be imported as imported.port, but got %+v", imported)
+	}
+	if Contains(parent.Schema.Required.Strings, "name") || Contains(parent.Schema.Required.Strings, "imported") {
+		t.Errorf("Expected imported values not to be required, but got %v", parent.Schema.Required.Strings)
+	}
+}
+
+func TestResolveDependenciesRefs(t *testing.T) {
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:    "parent",
+		Version: "1.0.0",
+		Dependencies: []*chart.Dependency{
+			{Name: "sub", Version: "0.1.0", Alias: "first"},
+			{Name: "sub", Version: "0.1.0", Alias: "second"},
+		},
+	}, "replicas: 1\n")
+	sub := newTestResult(t, &chart.ChartFile{
+		Name:         "sub",
+		Version:      "0.1.0",
+		Dependencies: []*chart.Dependency{{Name: "leaf", Version: "2.0.0"}},
+	}, `
+# @schema
+# type: integer
+# @schema
+port: 80
+`)
+	leaf := newTestResult(t, &chart.ChartFile{Name: "leaf", Version: "2.0.0"}, "enabled: true\n")
+
+	options := DefaultGeneratorOptions()
+	options.DependencyRefs = true
+	if _, err := NewGenerator(options).ResolveDependencies([]*Result{parent, sub, leaf}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	for _, alias := range []string{"first", "second"} {
+		if ref := parent.Schema.Properties[alias].Ref; ref != "#/$defs/sub-0.1.0" {
+			t.Errorf("Expected %s to reference the sub chart, but got %q", alias, ref)
+		}
+	}
+	if len(parent.Schema.Defs) != 2 || parent.Schema.Defs["sub-0.1.0"] == nil || parent.Schema.Defs["leaf-2.0.0"] == nil {
+		t.Fatalf("Expected the definitions of sub and leaf, but got %v", parent.Schema.Defs)
+	}
+	if ref := parent.Schema.Defs["sub-0.1.0"].Properties["leaf"].Ref; ref != "#/$defs/leaf-2.0.0" {
+		t.Errorf("Expected the sub chart to reference the leaf chart, but got %q", ref)
+	}
+	if parent.Schema.Defs["leaf-2.0.0"].Properties["global"] == nil {
+		t.Error("Expected the globals to be propagated into the referenced leaf schema")
+	}
+
+	compiled, err := parent.Schema.Compile()
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error while compiling, but got this: %v", err)
+	}
+	violations, err := ValidateValues(compiled, "values.yaml", []byte("replicas: 1\nsecond:\n  port: http\n  leaf:\n    enabled: true\n"))
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error while validating, but got this: %v", err)
+	}
+	if len(violations) != 1 || violations[0].Pointer != "/second/port" {
+		t.Errorf("Expected one violation of /second/port, but got %v", violations)
+	}
+}
+
+func TestResolveDependenciesRefsParentValues(t *testing.T) {
+	values := `
+sub:
+  image:
+    # @schema
+    # pattern: ^v
+    # @schema
+    tag: v2
+    pullPolicy: Always
+`
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:         "parent",
+		Version:      "1.0.0",
+		Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0"}, {Name: "sub", Version: "0.1.0", Alias: "other"}},
+	}, values)
+	sub := newTestResult(t, &chart.ChartFile{Name: "sub", Version: "0.1.0"}, "image:\n  tag: v1\n")
+
+	options := DefaultGeneratorOptions()
+	options.DependencyRefs = true
+	if _, err := NewGenerator(options).ResolveDependencies([]*Result{parent, sub}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	if ref := parent.Schema.Properties["other"].Ref; ref != "#/$defs/sub-0.1.0" {
+		t.Errorf("Expected the unchanged alias to reference the sub chart, but got %q", ref)
+	}
+
+	compiled, err := parent.Schema.Compile()
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error while compiling, but got this: %v", err)
+	}
+	violations, err := ValidateValues(compiled, "values.yaml", []byte(values))
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error while validating, but got this: %v", err)
+	}
+	if len(violations) > 0 {
+		t.Errorf("Expected the values of the parent to match its jsonschema, but got %v", violations)
+	}
+	violations, err = ValidateValues(compiled, "values.yaml", []byte("sub:\n  image:\n    tag: latest\n"))
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error while validating, but got this: %v", err)
+	}
+	if len(violations) != 1 || violations[0].Pointer != "/sub/image/tag" {
+		t.Errorf("Expected one violation of /sub/image/tag, but got %v", violations)
+	}
+}
+
+func TestResolveDependenciesParentAnnotations(t *testing.T) {
+	for _, dependencyRefs := range []bool{false, true} {
+		parent := newTestResult(t, &chart.ChartFile{
+			Name:         "parent",
+			Version:      "1.0.0",
+			Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0"}},
+		}, `
+sub:
+  # @schema
+  # enum: [http, https]
+  # required: true
+  # @schema
+  protocol: https
+  port: 8080
+`)
+		sub := newTestResult(t, &chart.ChartFile{Name: "sub", Version: "0.1.0"}, `
+protocol: http
+# @schema
+# type: integer
+# @schema
+port: 80
+`)
+
+		options := DefaultGeneratorOptions()
+		options.DependencyRefs = dependencyRefs
+		if _, err := NewGenerator(options).ResolveDependencies([]*Result{parent, sub}); err != nil {
+			t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+		}
+
+		subSchema := parent.Schema.Properties["sub"]
+		if dependencyRefs {
+			if len(subSchema.AllOf) != 1 || subSchema.AllOf[0].Ref != "#/$defs/sub-0.1.0" {
+				t.Fatalf("Expected the parent changes next to a reference, but got %+v", subSchema)
+			}
+			if subSchema.Properties["port"].Type != nil {
+				t.Errorf("Expected only the changed keywords of port, but got %+v", subSchema.Properties["port"])
+			}
+		} else {
+			if !subSchema.Properties["port"].Type.Matches("integer") {
+				t.Errorf("Expected the type of the dependency, but got %v", subSchema.Properties["port"].Type)
+			}
+		}
+
+		protocol := subSchema.Properties["protocol"]
+		if len(protocol.Enum) != 2 || protocol.Default != "https" {
+			t.Errorf("Expected the annotation and the value of the parent, but got %+v", protocol)
+		}
+		if port := subSchema.Properties["port"]; port.Default != 8080 {
+			t.Errorf("Expected the value of the parent as default, but got %v", port.Default)
+		}
+		if strings.Join(subSchema.Required.Strings, ",") != "protocol" {
+			t.Errorf("Expected only the explicitly required protocol to be required, but got %v", subSchema.Required.Strings)
+		}
+
+		compiled, err := parent.Schema.Compile()
+		if err != nil {
+			t.Fatalf("Wasn't expecting an error while compiling, but got this: %v", err)
+		}
+		violations, err := ValidateValues(compiled, "values.yaml", []byte("sub:\n  protocol: ftp\n  port: 1\n"))
+		if err != nil {
+			t.Fatalf("Wasn't expecting an error while validating, but got this: %v", err)
+		}
+		if len(violations) != 1 || violations[0].Pointer != "/sub/protocol" {
+			t.Errorf("Expected one violation of /sub/protocol, but got %v", violations)
+		}
+	}
+}
+
+func TestResolveDependenciesConditions(t *testing.T) {
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:    "parent",
+		Version: "1.0.0",
+		Dependencies: []*chart.Dependency{
+			{Name: "sub", Version: "0.1.0", Alias: "first", Condition: "first.enabled, global.first.enabled"},
+			{Name: "sub", Version: "0.1.0", Alias: "second", Condition: "second.active"},
+			{Name: "missing", Version: "1.0.0", Condition: "missingEnabled"},
+		},
+	}, "replicas: 1\n")
+	other := newTestResult(t, &chart.ChartFile{
+		Name:         "other",
+		Version:      "1.0.0",
+		Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0", Condition: "sub.on"}},
+	}, "replicas: 1\n")
+	sub := newTestResult(t, &chart.ChartFile{Name: "sub", Version: "0.1.0"}, "replicas: 1\n")
+
+	if _, err := NewGenerator(DefaultGeneratorOptions()).ResolveDependencies([]*Result{parent, other, sub}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	// the conditions of all parents are part of the dependency schema
+	for _, name := range []string{"enabled", "active", "on"} {
+		if condition := sub.Schema.Properties[name]; condition == nil || !condition.Type.Matches("boolean") {
+			t.Errorf("Expected the boolean condition %s in the schema of sub, but got %v", name, sub.Schema.PropertyNames())
+		}
+		if _, ok := parent.Schema.Properties["first"].Properties[name]; !ok {
+			t.Errorf("Expected the condition %s in the first alias", name)
+		}
+	}
+
+	global := parent.Schema.Properties["global"]
+	if condition := schemaAtPath(global, "first.enabled"); condition == nil || !condition.Type.Matches("boolean") {
+		t.Errorf("Expected the condition global.first.enabled in the parent schema")
+	}
+	if condition := schemaAtPath(parent.Schema.Properties["second"], "global.first.enabled"); condition == nil {
+		t.Errorf("Expected the condition global.first.enabled to be propagated to the dependencies")
+	}
+	if condition := parent.Schema.Properties["missingEnabled"]; condition == nil || !condition.Type.Matches("boolean") {
+		t.Errorf("Expected the condition of the missing dependency in the parent schema")
+	}
+	if Contains(parent.Schema.Required.Strings, "missingEnabled") {
+		t.Errorf("Expected the conditions to be optional, but got %v", parent.Schema.Required.Strings)
+	}
+}
+
+func TestResolveDependenciesFileRepositoryAndLock(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"common-v1/Chart.yaml":  "apiVersion: v2\nname: common\nversion: 1.0.0\n",
+		"common-v1/values.yaml": "first: 1\n",
+		"common-v2/Chart.yaml":  "apiVersion: v2\nname: common\nversion: 2.0.0\n",
+		"common-v2/values.yaml": "second: 2\n",
+		"app/Chart.yaml": `apiVersion: v2
+name: app
+version: 1.0.0
+dependencies:
+  - name: common
+    version: ">=1.0.0"
+    repository: file://../common-v2
+`,
+		"app/values.yaml": "replicas: 1\n",
+		"other/Chart.yaml": `apiVersion: v2
+name: other
+version: 1.0.0
+dependencies:
+  - name: common
+    version: ">=1.0.0"
+    repository: https://charts.example.com
+`,
+		"other/Chart.lock": `dependencies:
+  - name: common
+    version: 2.0.0
+    repository: https://charts.example.com
+`,
+		"other/values.yaml": "replicas: 1\n",
+		"lost/Chart.yaml": `apiVersion: v2
+name: lost
+version: 1.0.0
+dependencies:
+  - name: common
+    version: ">=1.0.0"
+    repository: file://../common-v3
+`,
+		"lost/values.yaml": "replicas: 1\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generator := NewGenerator(DefaultGeneratorOptions())
+	var results []*Result
+	for _, dir := range []string{"app", "other", "lost", "common-v1", "common-v2"} {
+		result := generator.GenerateForChart(filepath.Join(root, dir))
+		if len(result.Errors) > 0 {
+			t.Fatalf("Wasn't expecting errors, but got: %v", result.Errors)
+		}
+		results = append(results, result)
+	}
+	if _, err := generator.ResolveDependencies(results); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	if common := results[0].Schema.Properties["common"]; common == nil || common.Properties["second"] == nil {
+		t.Errorf("Expected the chart of the file:// repository to be used by app, but got %+v", common)
+	}
+	if common := results[1].Schema.Properties["common"]; common == nil || common.Properties["second"] == nil {
+		t.Errorf("Expected the locked version of common to be used by other, but got %+v", common)
+	}
+	if common := results[2].Schema.Properties["common"]; common != nil {
+		t.Errorf("Expected no chart for the missing file:// repository of lost, but got %+v", common)
+	}
+}
+
+func TestResolveDependenciesDuplicateArchiveErrors(t *testing.T) {
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:         "parent",
+		Version:      "1.0.0",
+		Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0"}},
+	}, "replicas: 1\n")
+	sub := newTestResult(t, &chart.ChartFile{Name: "sub", Version: "0.1.0"}, "port: 80\n")
+	packaged := &Result{
+		ChartPath: "parent/charts/sub-0.1.0.tgz/sub/Chart.yaml",
+		Archive:   true,
+		Chart:     &chart.ChartFile{Name: "sub", Version: "0.1.0"},
+		Errors:    []error{errors.New("broken values")},
+	}
+
+	results, err := NewGenerator(DefaultGeneratorOptions()).ResolveDependencies([]*Result{parent, sub, packaged})
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected the packaged duplicate to be dropped, but got %d results", len(results))
+	}
+	if len(sub.Errors) > 0 || len(parent.Errors) > 0 {
+		t.Errorf("Expected the errors of the packaged duplicate to be only logged, but got %v and %v", sub.Errors, parent.Errors)
+	}
+}
+
+func TestResolveDependenciesReadsChartOptionsOnce(t *testing.T) {
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:         "parent",
+		Version:      "1.0.0",
+		Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0"}, {Name: "sub", Version: "0.1.0", Alias: "other"}},
+	}, "replicas: 1\n")
+	sub := newTestResult(t, &chart.ChartFile{Name: "sub", Version: "0.1.0"}, "port: 80\n")
+
+	generator := NewGenerator(DefaultGeneratorOptions())
+	calls := make(map[string]int)
+	generator.SetChartOptions(func(chartDir string) (GeneratorOptions, error) {
+		calls[chartDir]++
+		return DefaultGeneratorOptions(), nil
+	})
+	if _, err := generator.ResolveDependencies([]*Result{parent, sub}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	for chartDir, count := range calls {
+		if count != 1 {
+			t.Errorf("Expected the options of %s to be read once, but they were read %d times", chartDir, count)
+		}
+	}
+	if len(calls) != 2 {
+		t.Errorf("Expected the options of 2 charts to be read, but got %v", calls)
+	}
+}
+
+func TestResolveDependenciesParentDefaults(t *testing.T) {
+	values := `
+sub:
+  # @schema
+  # description: The port of the service
+  # @schema
+  port: "9090"
+  replicas: "many"
+`
+	parent := newTestResult(t, &chart.ChartFile{
+		Name:         "parent",
+		Version:      "1.0.0",
+		Dependencies: []*chart.Dependency{{Name: "sub", Version: "0.1.0"}},
+	}, values)
+	parent.valuesPaths = []string{"values.yaml"}
+	parent.valuesContents = [][]byte{[]byte(values)}
+	sub := newTestResult(t, &chart.ChartFile{Name: "sub", Version: "0.1.0"}, "port: 80\nreplicas: 1\n")
+
+	if _, err := NewGenerator(DefaultGeneratorOptions()).ResolveDependencies([]*Result{parent, sub}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	port := parent.Schema.Properties["sub"].Properties["port"]
+	if !port.Type.Matches("integer") || port.Default != 9090 {
+		t.Errorf("Expected the default of the parent cast to the type of the dependency, but got %v %#v", port.Type, port.Default)
+	}
+	// the default which can't be cast doesn't match the type of the dependency
+	if len(parent.Errors) != 1 {
+		t.Fatalf("Expected 1 error, but got %d: %v", len(parent.Errors), parent.Errors)
+	}
+	var valuesErr *ValuesError
+	if !errors.As(parent.Errors[0], &valuesErr) || valuesErr.KeyPath != "sub.replicas" || valuesErr.Line != 7 {
+		t.Errorf("Expected an invalid default of sub.replicas in line 7, but got %v", parent.Errors[0])
+	}
+}
+
+func TestResolveDependenciesSelfCheck(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"parent/Chart.yaml": `apiVersion: v2
+name: parent
+version: 1.0.0
+dependencies:
+  - name: sub
+    version: 0.1.0
+    import-values:
+      - child: image
+        parent: image
+`,
+		"parent/values.yaml":            "image:\n  tag: latest\n",
+		"parent/charts/sub/Chart.yaml":  "apiVersion: v2\nname: sub\nversion: 0.1.0\n",
+		"parent/charts/sub/values.yaml": "image:\n  # @schema\n  # pattern: ^v\n  # @schema\n  tag: v1\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generator := NewGenerator(DefaultGeneratorOptions())
+	parent := generator.GenerateForChart(filepath.Join(root, "parent"))
+	sub := generator.GenerateForChart(filepath.Join(root, "parent", "charts", "sub"))
+	if len(parent.Errors) > 0 || len(sub.Errors) > 0 {
+		t.Fatalf("Wasn't expecting errors before the merge, but got: %v %v", parent.Errors, sub.Errors)
+	}
+	if _, err := generator.ResolveDependencies([]*Result{parent, sub}); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	// the pattern of the imported value is only part of the merged jsonschema
+	if len(parent.Errors) != 1 {
+		t.Fatalf("Expected 1 error, but got %d: %v", len(parent.Errors), parent.Errors)
+	}
+	var violation *Violation
+	if !errors.As(parent.Errors[0], &violation) || violation.Pointer != "/image/tag" || violation.Line != 2 {
+		t.Errorf("Expected a violation of /image/tag in line 2, but got %v", parent.Errors[0])
+	}
+	if len(sub.Errors) > 0 {
+		t.Errorf("Wasn't expecting errors for the dependency, but got: %v", sub.Errors)
+	}
+}
@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Draft is the jsonschema draft the generated schema follows
+type Draft int
+
+const (
+	// Draft7 is the default, because it's the only draft helm supports
+	Draft7 Draft = iota
+	Draft2019
+	Draft2020
+)
+
+// PossibleDrafts contains the names of all supported drafts
+var PossibleDrafts = []string{Draft7.String(), Draft2019.String(), Draft2020.String()}
+
+// ParseDraft converts a draft name like 7, 2019-09 or 2020-12 to a Draft
+func ParseDraft(name string) (Draft, error) {
+	switch strings.TrimPrefix(strings.ToLower(name), "draft-") {
+	case "7", "07":
+		return Draft7, nil
+	case "2019-09", "2019":
+		return Draft2019, nil
+	case "2020-12", "2020":
+		return Draft2020, nil
+	}
+	return Draft7, fmt.Errorf("unsupported jsonschema draft %s, use one of (%s)", name, strings.Join(PossibleDrafts, ", "))
+}
+
+// draftFromURI returns the draft of the given $schema uri, it defaults to Draft7
+func draftFromURI(uri string) Draft {
+	for _, d := range []Draft{Draft2019, Draft2020} {
+		if uri == d.URI() {
+			return d
+		}
+	}
+	return Draft7
+}
+
+func (d Draft) String() string {
+	switch d {
+	case Draft2019:
+		return "2019-09"
+	case Draft2020:
+		return "2020-12"
+	}
+	return "7"
+}
+
+// URI returns the value used for the $schema keyword
+func (d Draft) URI() string {
+	switch d {
+	case Draft2019:
+		return "https://json-schema.org/draft/2019-09/schema"
+	case Draft2020:
+		return "https://json-schema.org/draft/2020-12/schema"
+	}
+	return "http://json-schema.org/draft-07/schema#"
+}
+
+func (d Draft) compilerDraft() *jsonschema.Draft {
+	switch d {
+	case Draft2019:
+		return jsonschema.Draft2019
+	case Draft2020:
+		return jsonschema.Draft2020
+	}
+	return jsonschema.Draft7
+}
+
+var (
+	metaSchemasMu sync.Mutex
+	// metaSchemas contains the compiled meta-schema of every draft already used
+	metaSchemas = make(map[Draft]*jsonschema.Schema)
+)
+
+// metaSchema returns the compiled meta-schema of the draft, it's compiled only once
+func (d Draft) metaSchema() (*jsonschema.Schema, error) {
+	metaSchemasMu.Lock()
+	defer metaSchemasMu.Unlock()
+	if metaSchema, ok := metaSchemas[d]; ok {
+		return metaSchema, nil
+	}
+
+	c := jsonschema.NewCompiler()
+	c.Draft = d.compilerDraft()
+	metaSchema, err := c.Compile(c.Draft.URL())
+	if err != nil {
+		return nil, err
+	}
+	metaSchemas[d] = metaSchema
+	return metaSchema, nil
+}
+
+// convertKeywords rewrites the marshaled keywords of a single schema to the forms used by the draft
+func (d Draft) convertKeywords(data map[string]interface{}) {
+	// definitions were renamed to $defs in 2019-09
+	defsKey, oldDefsKey := "$defs", "definitions"
+	if d == Draft7 {
+		defsKey, oldDefsKey = oldDefsKey, defsKey
+	}
+	mergeKeyword(data, oldDefsKey, defsKey)
+
+	if ref, ok := data["$ref"].(string); ok && strings.HasPrefix(ref, "#/"+oldDefsKey+"/") {
+		data["$ref"] = "#/" + defsKey + "/" + strings.TrimPrefix(ref, "#/"+oldDefsKey+"/")
+	}
+
+	// dependentRequired and dependentSchemas replaced dependencies in 2019-09
+	if d == Draft7 {
+		mergeKeyword(data, "dependentRequired", "dependencies")
+		mergeKeyword(data, "dependentSchemas", "dependencies")
+	}
+
+	// prefixItems replaced the array form of items in 2020-12
+	if d != Draft2020 {
+		if prefixItems, ok := data["prefixItems"]; ok {
+			if items, ok := data["items"]; ok {
+				data["additionalItems"] = items
+			}
+			data["items"] = prefixItems
+			delete(data, "prefixItems")
+		}
+	}
+}
+
+// mergeKeyword moves the entries of the object keyword from into the object keyword to
+func mergeKeyword(data map[string]interface{}, from, to string) {
+	fromValue, ok := data[from].(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(data, from)
+
+	toValue, ok := data[to].(map[string]interface{})
+	if !ok {
+		data[to] = fromValue
+		return
+	}
+	for key, value := range fromValue {
+		if _, exists := toValue[key]; !exists {
+			toValue[key] = value
+		}
+	}
+}
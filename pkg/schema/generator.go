@@ -0,0 +1,418 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/winterRel/helm-schema/pkg/chart"
+	"github.com/winterRel/helm-schema/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// GeneratorOptions contains all options which influence the jsonschema generation
+type GeneratorOptions struct {
+	// ValueFileNames are the filenames checked (in order) for chart values,
+	// the first existing file is used
+	ValueFileNames []string
+	// Uncomment considers yaml which is commented out
+	Uncomment bool
+	// AddSchemaReference adds a reference to the jsonschema to the values file if not found
+	AddSchemaReference bool
+	// KeepFullComment keeps the whole leading comment instead of cutting it at the first empty line
+	KeepFullComment bool
+	// HelmDocsCompatibilityMode parses and uses helm-docs comments
+	HelmDocsCompatibilityMode bool
+	// DontRemoveHelmDocsPrefix disables the removal of the helm-docs prefix (--)
+	DontRemoveHelmDocsPrefix bool
+	// SkipAutoGeneration contains the fields which should not be created by default
+	SkipAutoGeneration SkipAutoGenerationConfig
+	// Draft is the jsonschema draft of the generated schema
+	Draft Draft
+	// NoDependencies disables merging the jsonschemas of the dependencies into the chart
+	NoDependencies bool
+	// MergeValueFiles uses all existing ValueFileNames instead of the first one and merges
+	// their jsonschemas in order
+	MergeValueFiles bool
+	// DependencyRefs places the jsonschema of every dependency once in $defs of the chart
+	// and references it from the dependency values instead of inlining it
+	DependencyRefs bool
+	// WarnInvalidDefaults logs enum values, defaults and examples which don't match their
+	// jsonschema as warnings instead of returning them as errors
+	WarnInvalidDefaults bool
+	// NoSelfCheck disables the validation of the values file against the generated jsonschema
+	NoSelfCheck bool
+}
+
+// DefaultGeneratorOptions returns the options used by the helm-schema cli if no flags are given
+func DefaultGeneratorOptions() GeneratorOptions {
+	return GeneratorOptions{
+		ValueFileNames: []string{"values.yaml"},
+	}
+}
+
+// Generator creates jsonschemas from helm charts and their values files
+type Generator struct {
+	options      GeneratorOptions
+	chartOptions func(chartDir string) (GeneratorOptions, error)
+
+	// chartOptionsCache contains the options of every chart directory already configured
+	chartOptionsCache map[string]GeneratorOptions
+	mu                sync.Mutex
+}
+
+// NewGenerator creates a new Generator with the given options
+func NewGenerator(options GeneratorOptions) *Generator {
+	return &Generator{options: options}
+}
+
+// Options returns the options of the generator
+func (g *Generator) Options() GeneratorOptions {
+	return g.options
+}
+
+// SetChartOptions registers a function which returns the options of the chart in chartDir.
+// It allows to configure charts differently, the options given to NewGenerator are used for
+// packaged charts only.
+func (g *Generator) SetChartOptions(chartOptions func(chartDir string) (GeneratorOptions, error)) {
+	g.chartOptions = chartOptions
+}
+
+// forChart returns the generator used for the chart in chartDir. The options of every
+// chart are only read once.
+func (g *Generator) forChart(chartDir string) (*Generator, error) {
+	if g.chartOptions == nil {
+		return g, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	options, ok := g.chartOptionsCache[chartDir]
+	if !ok {
+		var err error
+		options, err = g.chartOptions(chartDir)
+		if err != nil {
+			return nil, err
+		}
+		if g.chartOptionsCache == nil {
+			g.chartOptionsCache = make(map[string]GeneratorOptions)
+		}
+		g.chartOptionsCache[chartDir] = options
+	}
+	return &Generator{options: options}, nil
+}
+
+// ValuesError is returned if (a part of) a values file can't be turned into a jsonschema
+type ValuesError struct {
+	// ValuesPath is the path of the values file, empty if the values were read from a reader
+	ValuesPath string
+	// KeyPath is the dot separated path of the yaml key the error belongs to (e.g. image.tag or hosts[0].name)
+	KeyPath string
+	// Line and Column of the yaml node the error belongs to, zero if unknown
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ValuesError) Error() string {
+	var location []string
+	if e.ValuesPath != "" {
+		location = append(location, e.ValuesPath)
+	}
+	if e.Line > 0 {
+		location = append(location, fmt.Sprintf("%d:%d", e.Line, e.Column))
+	}
+
+	msg := e.Err.Error()
+	if e.KeyPath != "" {
+		msg = fmt.Sprintf("key %s: %s", e.KeyPath, msg)
+	}
+	if len(location) == 0 {
+		return msg
+	}
+	return strings.Join(location, ":") + ": " + msg
+}
+
+func (e *ValuesError) Unwrap() error { return e.Err }
+
+// GenerateForChart creates the jsonschema for the chart located in chartDir.
+// All problems found are collected in the Errors field of the returned Result.
+// The values files are checked against the jsonschema by ResolveDependencies.
+func (g *Generator) GenerateForChart(chartDir string) *Result {
+	result := &Result{ChartPath: filepath.Join(chartDir, "Chart.yaml")}
+	generator, err := g.forChart(chartDir)
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return result
+	}
+	generator.generateForChartFiles(result, chartDir, func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(chartDir, name))
+	})
+	return result
+}
+
+// GenerateForArchive creates the jsonschemas for the packaged chart (.tgz) at archivePath
+// and all charts packaged inside of it. Nothing is extracted to disk.
+func (g *Generator) GenerateForArchive(archivePath string) []*Result {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return []*Result{{ChartPath: archivePath, Archive: true, Errors: []error{err}}}
+	}
+	defer file.Close()
+
+	return g.generateForArchive(archivePath, file)
+}
+
+func (g *Generator) generateForArchive(archivePath string, reader io.Reader) []*Result {
+	files, err := util.ReadTarGz(reader)
+	if err != nil {
+		return []*Result{{ChartPath: archivePath, Archive: true, Errors: []error{err}}}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var results []*Result
+	for _, name := range names {
+		switch {
+		case path.Base(name) == "Chart.yaml":
+			chartRoot := path.Dir(name)
+			result := &Result{ChartPath: filepath.Join(archivePath, name), Archive: true}
+			g.generateForChartFiles(result, filepath.Join(archivePath, chartRoot), func(fileName string) ([]byte, error) {
+				if content, ok := files[path.Join(chartRoot, filepath.ToSlash(fileName))]; ok {
+					return content, nil
+				}
+				return nil, fs.ErrNotExist
+			})
+			results = append(results, result)
+		case path.Ext(name) == ".tgz" && path.Base(path.Dir(name)) == "charts":
+			// dependencies packaged into the archive
+			results = append(results, g.generateForArchive(filepath.Join(archivePath, name), bytes.NewReader(files[name]))...)
+		}
+	}
+
+	if len(results) == 0 {
+		return []*Result{{ChartPath: archivePath, Archive: true, Errors: []error{errors.New("no Chart.yaml found in archive")}}}
+	}
+	return results
+}
+
+// generateForChartFiles fills the result with the chart read via readFile,
+// which must return an error matching fs.ErrNotExist for missing files
+func (g *Generator) generateForChartFiles(result *Result, chartDir string, readFile func(name string) ([]byte, error)) {
+	chartContent, err := readFile("Chart.yaml")
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	chartFile, err := chart.ReadChart(bytes.NewReader(chartContent))
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+	if chartFile.APIVersion == chart.APIVersionV1 {
+		err = readRequirements(&chartFile, readFile)
+	} else {
+		err = applyLockFile(&chartFile, readFile, "Chart.lock")
+	}
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+	result.Chart = &chartFile
+
+	var valuesPaths []string
+	var valuesContents [][]byte
+	errorsWeMaybeCanIgnore := []error{}
+
+	for _, possibleValueFileName := range g.options.ValueFileNames {
+		valuesContent, err := readFile(possibleValueFileName)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errorsWeMaybeCanIgnore = append(errorsWeMaybeCanIgnore, err)
+			}
+			continue
+		}
+		valuesPaths = append(valuesPaths, filepath.Join(chartDir, possibleValueFileName))
+		valuesContents = append(valuesContents, valuesContent)
+		if !g.options.MergeValueFiles {
+			break
+		}
+	}
+
+	if len(valuesPaths) == 0 {
+		result.Errors = append(result.Errors, errorsWeMaybeCanIgnore...)
+		result.Errors = append(result.Errors, errors.New("no values file found"))
+		return
+	}
+	result.ValuesPath = valuesPaths[0]
+
+	var mergedSchema *Schema
+	var checkedContents [][]byte
+	for i, valuesPath := range valuesPaths {
+		content, err := util.ReadFileAndFixNewline(bytes.NewReader(valuesContents[i]))
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		checkedContents = append(checkedContents, content)
+
+		// Check if we need to add a schema reference (packaged charts can't be changed)
+		if i == 0 && g.options.AddSchemaReference && !result.Archive {
+			schemaRef := `# yaml-language-server: $schema=values.schema.json`
+			if !strings.Contains(string(content), schemaRef) {
+				err = util.PrefixFirstYamlDocument(schemaRef, valuesPath)
+				if err != nil {
+					result.Errors = append(result.Errors, err)
+					continue
+				}
+			}
+		}
+
+		schema, errs := g.generateFromValues(valuesPath, content)
+		if len(errs) > 0 {
+			result.Errors = append(result.Errors, errs...)
+			continue
+		}
+		if mergedSchema == nil {
+			mergedSchema = schema
+		} else {
+			log.Debugf("Merging jsonschema of %s", valuesPath)
+			mergedSchema = mergeSchemas(mergedSchema, schema, false)
+		}
+	}
+	if len(result.Errors) > 0 {
+		return
+	}
+	result.Schema = *mergedSchema
+	result.valuesPaths = valuesPaths
+	result.valuesContents = checkedContents
+}
+
+// selfCheck validates the values files the jsonschema was generated from against it, so annotations
+// contradicting the values (e.g. type: integer for "8080") are found before helm uses them.
+// The values files after the first one are merged with it by helm, so they don't need the required keys.
+func selfCheck(s *Schema, valuesPaths []string, contents [][]byte) []error {
+	var errs []error
+	for i, valuesPath := range valuesPaths {
+		checked := s
+		if i > 0 {
+			checked = s.Clone()
+			checked.DisableRequiredProperties()
+		}
+		compiled, err := checked.Compile()
+		if err != nil {
+			errs = append(errs, &ValuesError{ValuesPath: valuesPath, Err: fmt.Errorf("can't compile the generated jsonschema for the self check: %w", err)})
+			continue
+		}
+		violations, err := ValidateValues(compiled, valuesPath, contents[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, violation := range violations {
+			errs = append(errs, violation)
+		}
+	}
+	return errs
+}
+
+// GenerateFromValues creates the jsonschema for the values read from reader.
+// Relative $ref annotations are resolved relative to the current working directory.
+// If problems are found, the returned error joins all *ValuesError found.
+func (g *Generator) GenerateFromValues(reader io.Reader) (*Schema, error) {
+	content, err := util.ReadFileAndFixNewline(reader)
+	if err != nil {
+		return nil, &ValuesError{Err: err}
+	}
+	schema, errs := g.generateFromValues("", content)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return schema, nil
+}
+
+func (g *Generator) generateFromValues(valuesPath string, content []byte) (*Schema, []error) {
+	var err error
+
+	// Optional preprocessing
+	if g.options.Uncomment {
+		// Remove comments from valid yaml
+		content, err = util.RemoveCommentsFromYaml(bytes.NewReader(content))
+		if err != nil {
+			return nil, []error{&ValuesError{ValuesPath: valuesPath, Err: err}}
+		}
+	}
+
+	var values yaml.Node
+	err = yaml.Unmarshal(content, &values)
+	if err != nil {
+		return nil, []error{&ValuesError{ValuesPath: valuesPath, Err: err}}
+	}
+
+	schema, errs := YamlToSchema(valuesPath, &values, &g.options, nil)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	for _, err := range checkDefaults(valuesPath, &values, schema) {
+		if g.options.WarnInvalidDefaults {
+			log.Warnln(err)
+			continue
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return schema, nil
+}
+
+// readRequirements adds the dependencies of the requirements.yaml of apiVersion v1 charts
+// and pins their versions to the ones in requirements.lock, if the files exist
+func readRequirements(chartFile *chart.ChartFile, readFile func(name string) ([]byte, error)) error {
+	content, err := readFile("requirements.yaml")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	dependencies, err := chart.ReadRequirements(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("could not parse requirements.yaml: %w", err)
+	}
+	chartFile.Dependencies = append(chartFile.Dependencies, dependencies...)
+
+	return applyLockFile(chartFile, readFile, "requirements.lock")
+}
+
+// applyLockFile pins the versions of the dependencies to the ones in the lock file, if it exists
+func applyLockFile(chartFile *chart.ChartFile, readFile func(name string) ([]byte, error), fileName string) error {
+	content, err := readFile(fileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	lock, err := chart.ReadLock(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", fileName, err)
+	}
+	chartFile.ApplyLock(lock)
+	return nil
+}
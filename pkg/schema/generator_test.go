@@ -0,0 +1,413 @@
+package schema
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFromValues(t *testing.T) {
+	generator := NewGenerator(DefaultGeneratorOptions())
+
+	schema, err := generator.GenerateFromValues(strings.NewReader(`
+# @schema
+# type: integer
+# minimum: 1
+# @schema
+replicas: 1
+image:
+  tag: latest
+`))
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	if schema.Schema != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("Expected draft-07 $schema, but got %s", schema.Schema)
+	}
+	if !schema.Properties["replicas"].Type.Matches("integer") {
+		t.Errorf("Expected replicas to be an integer, but got %v", schema.Properties["replicas"].Type)
+	}
+	if schema.Properties["image"].Properties["tag"].Default != "latest" {
+		t.Errorf("Expected image.tag default to be latest, but got %v", schema.Properties["image"].Properties["tag"].Default)
+	}
+	if !Contains(schema.Required.Strings, "image") {
+		t.Errorf("Expected image to be required, but got %v", schema.Required.Strings)
+	}
+
+	if _, err := generator.GenerateFromValues(strings.NewReader("foo: [")); err == nil {
+		t.Error("Expected an error for invalid yaml, but got none")
+	}
+}
+
+func TestGenerateFromValuesInvalidDefaults(t *testing.T) {
+	values := `
+# @schema
+# type: integer
+# minimum: 5
+# @schema
+replicas: 1
+image:
+  # @schema
+  # pattern: ^v
+  # examples: [v1]
+  # @schema
+  tag: latest
+empty:
+`
+	options := DefaultGeneratorOptions()
+	_, err := NewGenerator(options).GenerateFromValues(strings.NewReader(values))
+	if err == nil {
+		t.Fatal("Expected errors for the invalid defaults, but got none")
+	}
+
+	expected := []struct {
+		keyPath string
+		line    int
+	}{
+		{keyPath: "replicas", line: 6},
+		{keyPath: "image.tag", line: 12},
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, but got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		var valuesErr *ValuesError
+		if !errors.As(errs[i], &valuesErr) {
+			t.Fatalf("Expected a ValuesError, but got %T", errs[i])
+		}
+		if valuesErr.KeyPath != exp.keyPath || valuesErr.Line != exp.line {
+			t.Errorf("Expected error at line %d for key %s, but got %v", exp.line, exp.keyPath, valuesErr)
+		}
+	}
+
+	options.WarnInvalidDefaults = true
+	if _, err := NewGenerator(options).GenerateFromValues(strings.NewReader(values)); err != nil {
+		t.Errorf("Expected only warnings, but got: %v", err)
+	}
+}
+
+func TestGenerateFromValuesInvalidSubschemaDefaults(t *testing.T) {
+	values := `
+# @schema
+# type: array
+# items:
+#   type: integer
+#   default: a
+# @schema
+ports: []
+image:
+  # @schema
+  # anyOf:
+  #   - type: string
+  #     examples: [1]
+  #   - type: "null"
+  # @schema
+  tag: latest
+# @schema
+# type: object
+# additionalProperties:
+#   type: string
+#   default: 5
+# @schema
+labels: {}
+`
+	_, err := NewGenerator(DefaultGeneratorOptions()).GenerateFromValues(strings.NewReader(values))
+	if err == nil {
+		t.Fatal("Expected errors for the invalid defaults, but got none")
+	}
+
+	expected := []struct {
+		keyPath string
+		line    int
+		message string
+	}{
+		{keyPath: "ports", line: 8, message: "items: default value \"a\""},
+		{keyPath: "image.tag", line: 16, message: "anyOf/0: examples value 1"},
+		{keyPath: "labels", line: 23, message: "additionalProperties: default value 5"},
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, but got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		var valuesErr *ValuesError
+		if !errors.As(errs[i], &valuesErr) {
+			t.Fatalf("Expected a ValuesError, but got %T", errs[i])
+		}
+		if valuesErr.KeyPath != exp.keyPath || valuesErr.Line != exp.line || !strings.Contains(valuesErr.Err.Error(), exp.message) {
+			t.Errorf("Expected error %q at line %d for key %s, but got %v", exp.message, exp.line, exp.keyPath, valuesErr)
+		}
+	}
+}
+
+func TestGenerateFromValuesNullDefault(t *testing.T) {
+	schema, err := NewGenerator(DefaultGeneratorOptions()).GenerateFromValues(strings.NewReader(`
+empty:
+tilde: ~
+name: ""
+`))
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	for _, name := range []string{"empty", "tilde"} {
+		if schema.Properties[name].Default != nil {
+			t.Errorf("Expected no default for the null value of %s, but got %#v", name, schema.Properties[name].Default)
+		}
+	}
+	if schema.Properties["name"].Default != "" {
+		t.Errorf("Expected the empty string default of name, but got %#v", schema.Properties["name"].Default)
+	}
+}
+
+func TestGenerateFromValuesInvalidExamples(t *testing.T) {
+	values := `
+# @schema
+# type: object
+# examples: ["API_KEY: secret"]
+# @schema
+conf: {}
+`
+	options := DefaultGeneratorOptions()
+	_, err := NewGenerator(options).GenerateFromValues(strings.NewReader(values))
+	var invalidValueErr *InvalidValueError
+	if !errors.As(err, &invalidValueErr) || invalidValueErr.Keyword != "examples" {
+		t.Fatalf("Expected an error for the string example, but got: %v", err)
+	}
+
+	options.WarnInvalidDefaults = true
+	schema, err := NewGenerator(options).GenerateFromValues(strings.NewReader(values))
+	if err != nil {
+		t.Fatalf("Expected only warnings, but got: %v", err)
+	}
+	if len(schema.Properties["conf"].Examples) != 1 {
+		t.Errorf("Expected the example to be kept, but got %v", schema.Properties["conf"].Examples)
+	}
+}
+
+func TestGenerateForArchive(t *testing.T) {
+	files := map[string]string{
+		"parent/Chart.yaml":  "name: parent\nversion: 1.0.0\n",
+		"parent/values.yaml": "foo: bar\n",
+	}
+	subArchive := createTarGz(t, map[string]string{
+		"sub/Chart.yaml":  "name: sub\nversion: 0.1.0\n",
+		"sub/values.yaml": "enabled: true\n",
+	})
+	files["parent/charts/sub-0.1.0.tgz"] = subArchive.String()
+
+	archivePath := filepath.Join(t.TempDir(), "parent-1.0.0.tgz")
+	if err := os.WriteFile(archivePath, createTarGz(t, files).Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := NewGenerator(DefaultGeneratorOptions()).GenerateForArchive(archivePath)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, but got %d", len(results))
+	}
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			t.Fatalf("Wasn't expecting errors, but got: %v", result.Errors)
+		}
+		if !result.Archive {
+			t.Errorf("Expected result %s to be marked as archive", result.ChartPath)
+		}
+	}
+	if results[0].Chart.Name != "parent" || results[0].Schema.Properties["foo"] == nil {
+		t.Errorf("Expected the parent chart with key foo, but got %s: %v", results[0].Chart.Name, results[0].Schema.Properties)
+	}
+	if results[1].Chart.Name != "sub" || results[1].Schema.Properties["enabled"] == nil {
+		t.Errorf("Expected the sub chart with key enabled, but got %s: %v", results[1].Chart.Name, results[1].Schema.Properties)
+	}
+}
+
+func createTarGz(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestGenerateForChartRequirements(t *testing.T) {
+	chartDir := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml":        "apiVersion: v1\nname: parent\nversion: 1.0.0\n",
+		"values.yaml":       "foo: bar\n",
+		"requirements.yaml": "dependencies:\n  - name: sub\n    version: ~0.1.0\n    condition: sub.enabled\n",
+		"requirements.lock": "dependencies:\n  - name: sub\n    version: 0.1.3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chartDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := NewGenerator(DefaultGeneratorOptions()).GenerateForChart(chartDir)
+	if len(result.Errors) > 0 {
+		t.Fatalf("Wasn't expecting errors, but got: %v", result.Errors)
+	}
+	if len(result.Chart.Dependencies) != 1 {
+		t.Fatalf("Expected the dependency of requirements.yaml, but got %v", result.Chart.Dependencies)
+	}
+	if dep := result.Chart.Dependencies[0]; dep.Condition != "sub.enabled" || dep.Version != "0.1.3" {
+		t.Errorf("Expected the condition of requirements.yaml and the version of requirements.lock, but got %+v", dep)
+	}
+}
+
+func TestGenerateForChartSelfCheck(t *testing.T) {
+	chartDir := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml": "name: app\nversion: 1.0.0\n",
+		"values.yaml": `
+# @schema
+# type: integer
+# @schema
+port: "8080"
+name: app
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chartDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options := DefaultGeneratorOptions()
+	generator := NewGenerator(options)
+	result := generator.GenerateForChart(chartDir)
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected the values to be checked with the dependencies, but got: %v", result.Errors)
+	}
+	if _, err := generator.ResolveDependencies([]*Result{result}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("Expected 1 error, but got %d: %v", len(result.Errors), result.Errors)
+	}
+	var violation *Violation
+	if !errors.As(result.Errors[0], &violation) {
+		t.Fatalf("Expected a Violation, but got %T", result.Errors[0])
+	}
+	if violation.Pointer != "/port" || violation.Line != 5 {
+		t.Errorf("Expected a violation of /port in line 5, but got %v", violation)
+	}
+
+	options.NoSelfCheck = true
+	generator = NewGenerator(options)
+	result = generator.GenerateForChart(chartDir)
+	if _, err := generator.ResolveDependencies([]*Result{result}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) > 0 {
+		t.Errorf("Wasn't expecting errors without the self check, but got: %v", result.Errors)
+	}
+}
+
+func TestSelfCheckCompileError(t *testing.T) {
+	s := &Schema{Ref: "#/definitions/missing"}
+	errs := selfCheck(s, []string{"values.yaml"}, [][]byte{[]byte("port: 80\n")})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "can't compile") {
+		t.Errorf("Expected the compile error to be reported, but got: %v", errs)
+	}
+}
+
+func TestGenerateForChartMergeValueFiles(t *testing.T) {
+	chartDir := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml": "name: app\nversion: 1.0.0\n",
+		"values.yaml": `
+port: 80
+image:
+  tag: latest
+`,
+		"values-ci.yaml": `
+port: http
+image:
+  # @schema
+  # pattern: ^v
+  # @schema
+  tag: v1
+  pullPolicy: Always
+ci:
+  enabled: true
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chartDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options := DefaultGeneratorOptions()
+	options.ValueFileNames = []string{"values.yaml", "values-missing.yaml", "values-ci.yaml"}
+	options.MergeValueFiles = true
+	generator := NewGenerator(options)
+	result := generator.GenerateForChart(chartDir)
+	if _, err := generator.ResolveDependencies([]*Result{result}); err != nil {
+		t.Fatal(err)
+	}
+	// the pattern of values-ci.yaml is checked against the tag of values.yaml too
+	if len(result.Errors) != 1 {
+		t.Fatalf("Expected 1 error, but got %d: %v", len(result.Errors), result.Errors)
+	}
+	var violation *Violation
+	if !errors.As(result.Errors[0], &violation) {
+		t.Fatalf("Expected a Violation, but got %T", result.Errors[0])
+	}
+	if violation.ValuesPath != filepath.Join(chartDir, "values.yaml") || violation.Pointer != "/image/tag" || violation.Line != 4 {
+		t.Errorf("Expected a violation of /image/tag in line 4 of values.yaml, but got %v", violation)
+	}
+	if result.ValuesPath != filepath.Join(chartDir, "values.yaml") {
+		t.Errorf("Expected the first values file as ValuesPath, but got %s", result.ValuesPath)
+	}
+
+	schema := result.Schema
+	if got := schema.Properties["port"].Type; len(got) != 2 || !got.Matches("integer") || !got.Matches("string") {
+		t.Errorf("Expected the type of port to be widened, but got %v", got)
+	}
+	if got := schema.Properties["port"].Default; got != 80 {
+		t.Errorf("Expected the default of the first values file, but got %v", got)
+	}
+	tag := schema.Properties["image"].Properties["tag"]
+	if tag.Pattern != "^v" || tag.Default != "v1" {
+		t.Errorf("Expected the annotated image.tag schema, but got %+v", tag)
+	}
+	if _, ok := schema.Properties["image"].Properties["pullPolicy"]; !ok {
+		t.Error("Expected image.pullPolicy to be added")
+	}
+	if _, ok := schema.Properties["ci"]; !ok {
+		t.Error("Expected ci to be added")
+	}
+	if !Contains(schema.Required.Strings, "image") || Contains(schema.Required.Strings, "ci") {
+		t.Errorf("Expected only the keys of values.yaml to be required, but got %v", schema.Required.Strings)
+	}
+	if got := schema.PropertyNames(); strings.Join(got, ",") != "port,image,global,ci" {
+		t.Errorf("Expected the property order of the values files, but got %v", got)
+	}
+
+	// without merging the first existing file wins
+	options.MergeValueFiles = false
+	result = NewGenerator(options).GenerateForChart(chartDir)
+	if _, ok := result.Schema.Properties["ci"]; ok {
+		t.Error("Expected ci to be missing without merging")
+	}
+}
@@ -0,0 +1,300 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// mergeSchemas combines two schemas generated from different values into a new one.
+// Properties of both schemas are merged recursively, differing types are widened and
+// keywords are taken from the annotated schema (or base, if both or none are annotated)
+// and filled up with the keywords of the other one.
+//
+// If requiredInBoth is set, a property is only required if it's required in both schemas,
+// otherwise the required properties of base are kept and other may only add properties it
+// requires explicitly (required: true) or which base has as well.
+func mergeSchemas(base, other *Schema, requiredInBoth bool) *Schema {
+	if base == nil {
+		return other.Clone()
+	}
+	if other == nil {
+		return base.Clone()
+	}
+
+	primary, secondary := base, other
+	if other.HasData && !base.HasData {
+		primary, secondary = other, base
+	}
+	merged := primary.Clone()
+	secondary = secondary.Clone()
+
+	// take over all keywords only set in the secondary schema
+	mergedValue := reflect.ValueOf(merged).Elem()
+	secondaryValue := reflect.ValueOf(secondary).Elem()
+	for i := 0; i < mergedValue.NumField(); i++ {
+		field := mergedValue.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch field.Name {
+		case "Properties", "Required", "Items", "AnyOf", "HasData":
+			continue
+		}
+		if mergedValue.Field(i).IsZero() && !secondaryValue.Field(i).IsZero() {
+			mergedValue.Field(i).Set(secondaryValue.Field(i))
+		}
+	}
+	merged.HasData = base.HasData || other.HasData
+
+	// widen the type
+	if len(merged.Type) > 0 && len(secondary.Type) > 0 {
+		for _, t := range secondary.Type {
+			if !Contains(merged.Type, t) {
+				merged.Type = append(merged.Type, t)
+			}
+		}
+	}
+
+	// merge the properties in the order of base
+	if base.Properties != nil || other.Properties != nil {
+		merged.Properties = make(map[string]*Schema)
+		merged.propertyOrder = nil
+		for _, schema := range []*Schema{base, other} {
+			for _, name := range schema.PropertyNames() {
+				if _, ok := merged.Properties[name]; ok {
+					continue
+				}
+				merged.Properties[name] = mergeSchemas(base.Properties[name], other.Properties[name], requiredInBoth)
+				merged.propertyOrder = append(merged.propertyOrder, name)
+			}
+		}
+	}
+
+	merged.Required = NewBoolOrArrayOfString(mergeRequired(base, other, requiredInBoth), base.Required.Bool || other.Required.Bool)
+
+	if base.Items != nil || other.Items != nil {
+		merged.Items = mergeSchemas(base.Items, other.Items, requiredInBoth)
+	}
+
+	merged.AnyOf = nil
+	for _, schema := range append(cloneSchemaSlice(base.AnyOf), cloneSchemaSlice(other.AnyOf)...) {
+		if !containsSchema(merged.AnyOf, schema) {
+			merged.AnyOf = append(merged.AnyOf, schema)
+		}
+	}
+
+	return merged
+}
+
+// mergeRequired returns the required properties of the merged schema
+func mergeRequired(base, other *Schema, requiredInBoth bool) []string {
+	result := []string{}
+	for _, name := range base.Required.Strings {
+		if !requiredInBoth || Contains(other.Required.Strings, name) {
+			result = append(result, name)
+		}
+	}
+	if requiredInBoth {
+		return result
+	}
+	for _, name := range other.Required.Strings {
+		if Contains(result, name) {
+			continue
+		}
+		_, inBase := base.Properties[name]
+		if property, ok := other.Properties[name]; inBase || (ok && property.Required.Bool) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// containsSchema checks if an equal schema is part of the list
+func containsSchema(list []*Schema, schema *Schema) bool {
+	for _, s := range list {
+		if reflect.DeepEqual(s, schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// inferItemsSchema creates one schema for all elements of a sequence. Elements of the same type
+// are merged (objects get the union of properties, required only if present in all elements),
+// only elements of different types result in an anyOf.
+func inferItemsSchema(itemSchemas []*Schema) *Schema {
+	var types []string
+	byType := make(map[string]*Schema)
+	for _, itemSchema := range itemSchemas {
+		itemType := strings.Join(itemSchema.Type, ",")
+		if merged, ok := byType[itemType]; ok {
+			byType[itemType] = mergeSchemas(merged, itemSchema, true)
+			continue
+		}
+		types = append(types, itemType)
+		byType[itemType] = itemSchema
+	}
+
+	switch len(types) {
+	case 0:
+		return NewSchema("")
+	case 1:
+		return byType[types[0]]
+	}
+
+	itemsSchema := NewSchema("")
+	for _, itemType := range types {
+		itemsSchema.AnyOf = append(itemsSchema.AnyOf, byType[itemType])
+	}
+	return itemsSchema
+}
+
+// overlaySchema applies the schema generated for the values of a dependency in the parent values
+// file on top of the schema of the dependency. Annotated parent schemas replace the keywords they
+// set, otherwise only the default and the description are taken over. Properties only the parent
+// defines are added, but only properties the parent explicitly requires are required.
+func overlaySchema(base, overlay *Schema) *Schema {
+	if overlay == nil {
+		return base.Clone()
+	}
+	if base == nil {
+		result := overlay.Clone()
+		result.keepExplicitRequired()
+		return result
+	}
+
+	result := base.Clone()
+	if overlay.HasData {
+		resultValue := reflect.ValueOf(result).Elem()
+		overlayValue := reflect.ValueOf(overlay.Clone()).Elem()
+		for i := 0; i < resultValue.NumField(); i++ {
+			field := resultValue.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			switch field.Name {
+			case "Properties", "Required", "HasData":
+				continue
+			}
+			if !overlayValue.Field(i).IsZero() {
+				resultValue.Field(i).Set(overlayValue.Field(i))
+			}
+		}
+		result.HasData = true
+	} else {
+		if overlay.Default != nil {
+			result.Default = overlay.Default
+		}
+		if overlay.Description != "" {
+			result.Description = overlay.Description
+		}
+	}
+	// the values of the parent were cast without knowing the type of the dependency
+	if value, ok := overlay.Default.(string); ok && (!overlay.HasData || len(overlay.Type) == 0) {
+		result.Default = castNodeValueByType(value, result.Type)
+	}
+
+	if len(overlay.Properties) > 0 {
+		if result.Properties == nil {
+			result.Properties = make(map[string]*Schema)
+		}
+		result.propertyOrder = result.PropertyNames()
+		for _, name := range overlay.PropertyNames() {
+			if _, ok := result.Properties[name]; !ok {
+				result.propertyOrder = append(result.propertyOrder, name)
+			}
+			result.Properties[name] = overlaySchema(result.Properties[name], overlay.Properties[name])
+			if overlay.Properties[name].Required.Bool && !Contains(result.Required.Strings, name) {
+				result.Required.Strings = append(result.Required.Strings, name)
+			}
+		}
+	}
+	return result
+}
+
+// widensSchema checks if changed accepts values base rejects, because it adds properties or types.
+// Such changes can't be placed next to a reference to base, because allOf only narrows it.
+func widensSchema(base, changed *Schema) bool {
+	if len(base.Type) > 0 {
+		for _, t := range changed.Type {
+			if !Contains(base.Type, t) {
+				return true
+			}
+		}
+	}
+	for _, name := range changed.PropertyNames() {
+		baseProperty, ok := base.Properties[name]
+		if !ok || widensSchema(baseProperty, changed.Properties[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepExplicitRequired removes all properties from the required lists, which aren't required explicitly
+func (s *Schema) keepExplicitRequired() {
+	required := []string{}
+	for _, name := range s.Required.Strings {
+		if property, ok := s.Properties[name]; ok && property.Required.Bool {
+			required = append(required, name)
+		}
+	}
+	s.Required.Strings = required
+	for _, property := range s.Properties {
+		property.keepExplicitRequired()
+	}
+}
+
+// schemaDiff returns a schema containing only the keywords and properties which differ between
+// base and changed, it's nil if there are no differences
+func schemaDiff(base, changed *Schema) *Schema {
+	diff := &Schema{}
+	differs := false
+
+	baseValue := reflect.ValueOf(base).Elem()
+	changedValue := reflect.ValueOf(changed).Elem()
+	diffValue := reflect.ValueOf(diff).Elem()
+	for i := 0; i < diffValue.NumField(); i++ {
+		field := diffValue.Type().Field(i)
+		if !field.IsExported() || field.Name == "Properties" || field.Name == "HasData" {
+			continue
+		}
+		if !equalKeyword(baseValue.Field(i), changedValue.Field(i)) {
+			diffValue.Field(i).Set(changedValue.Field(i))
+			differs = true
+		}
+	}
+
+	for _, name := range changed.PropertyNames() {
+		propertyDiff := changed.Properties[name]
+		if baseProperty, ok := base.Properties[name]; ok {
+			propertyDiff = schemaDiff(baseProperty, propertyDiff)
+		}
+		if propertyDiff == nil {
+			continue
+		}
+		if diff.Properties == nil {
+			diff.Properties = make(map[string]*Schema)
+		}
+		diff.Properties[name] = propertyDiff
+		diff.propertyOrder = append(diff.propertyOrder, name)
+		differs = true
+	}
+
+	if !differs {
+		return nil
+	}
+	return diff
+}
+
+// equalKeyword compares the values of a keyword in their marshaled form, so e.g. nil and empty lists are equal
+func equalKeyword(a, b reflect.Value) bool {
+	aJson, aErr := json.Marshal(a.Addr().Interface())
+	bJson, bErr := json.Marshal(b.Addr().Interface())
+	if aErr != nil || bErr != nil {
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	return bytes.Equal(aJson, bJson)
+}
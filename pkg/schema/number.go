@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonNumberPattern matches the number syntax of json
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Number is the value of a numeric keyword (e.g. minimum or multipleOf).
+// Integers and decimals are kept exactly as written.
+type Number string
+
+// NewNumber parses a json number, it returns an error for anything else
+func NewNumber(value string) (Number, error) {
+	if !jsonNumberPattern.MatchString(value) {
+		return "", fmt.Errorf("%s is not a number", value)
+	}
+	return Number(value), nil
+}
+
+func (n *Number) UnmarshalYAML(value *yaml.Node) error {
+	var number Number
+	var err error
+	switch value.ShortTag() {
+	case intTag:
+		number, err = NewNumber(value.Value)
+		if err != nil {
+			// other yaml notations like 0x1F, 0o17 or 1_000
+			i, ok := new(big.Int).SetString(value.Value, 0)
+			if !ok {
+				return fmt.Errorf("%s is not a number", value.Value)
+			}
+			number, err = NewNumber(i.String())
+		}
+	case floatTag:
+		number, err = NewNumber(value.Value)
+		if err != nil {
+			// other yaml notations like 1. or .5
+			var f float64
+			if err := value.Decode(&f); err != nil {
+				return err
+			}
+			number, err = NewNumber(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	default:
+		err = fmt.Errorf("%s is not a number", value.Value)
+	}
+	if err != nil {
+		return err
+	}
+	*n = number
+	return nil
+}
+
+func (n *Number) UnmarshalJSON(value []byte) error {
+	number, err := NewNumber(string(value))
+	if err != nil {
+		return err
+	}
+	*n = number
+	return nil
+}
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	if _, err := NewNumber(string(n)); err != nil {
+		return nil, err
+	}
+	return []byte(n), nil
+}
+
+// Rat returns the exact value of the number
+func (n Number) Rat() *big.Rat {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// Cmp compares the numbers like big.Rat.Cmp does
+func (n Number) Cmp(other Number) int {
+	return n.Rat().Cmp(other.Rat())
+}
+
+func (n Number) String() string {
+	return string(n)
+}
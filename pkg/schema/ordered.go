@@ -0,0 +1,237 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// keywordOrder is the conventional order of the keywords in ordered output,
+// unknown keywords (e.g. custom annotations) follow in alphabetical order
+var keywordOrder = []string{
+	"$schema", "$id", "$ref", "$comment", "title", "description", "type", "format",
+	"enum", "const", "default", "examples", "deprecated", "readOnly", "writeOnly",
+	"multipleOf", "minimum", "exclusiveMinimum", "maximum", "exclusiveMaximum",
+	"minLength", "maxLength", "pattern", "minItems", "maxItems", "uniqueItems",
+	"minContains", "maxContains", "minProperties", "maxProperties",
+	"required", "dependentRequired", "properties", "patternProperties", "propertyNames",
+	"additionalProperties", "unevaluatedProperties", "dependentSchemas", "dependencies",
+	"prefixItems", "items", "additionalItems", "contains", "if", "then", "else",
+	"allOf", "anyOf", "oneOf", "not", "$defs", "definitions",
+}
+
+// ToOrderedJson converts the data to raw json like ToJson, but the properties keep the order of
+// the values file and the keywords follow a fixed conventional order, which keeps diffs readable
+func (s Schema) ToOrderedJson() ([]byte, error) {
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeJSON(jsonStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var compact bytes.Buffer
+	if err := writeOrderedSchema(&compact, data, &s); err != nil {
+		return nil, err
+	}
+
+	var result bytes.Buffer
+	if err := json.Indent(&result, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+// PropertyNames returns the names of the properties in the order of the values file,
+// properties which weren't read from the values file follow in alphabetical order
+func (s *Schema) PropertyNames() []string {
+	properties := make(map[string]interface{}, len(s.Properties))
+	for name, property := range s.Properties {
+		properties[name] = property
+	}
+	return orderedKeys(properties, s.propertyOrder)
+}
+
+// writeOrderedSchema writes the marshaled schema data, s is the schema the data was created from (if known)
+func writeOrderedSchema(buf *bytes.Buffer, data interface{}, s *Schema) error {
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		// boolean schemas
+		return writeJSON(buf, data)
+	}
+
+	buf.WriteByte('{')
+	for i, key := range orderedKeys(object, keywordOrder) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSON(buf, key); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := writeOrderedKeyword(buf, key, object[key], s); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeOrderedKeyword writes the value of the keyword, subschemas are written ordered as well
+func writeOrderedKeyword(buf *bytes.Buffer, key string, value interface{}, s *Schema) error {
+	switch key {
+	case "properties", "patternProperties", "dependentSchemas", "dependencies", "$defs", "definitions":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return writeJSON(buf, value)
+		}
+		var order []string
+		if key == "properties" && s != nil {
+			order = s.propertyOrder
+		}
+
+		buf.WriteByte('{')
+		for i, name := range orderedKeys(object, order) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, name); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeOrderedSchema(buf, object[name], namedSubschema(s, key, name)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case "prefixItems", "items", "allOf", "anyOf", "oneOf":
+		list, ok := value.([]interface{})
+		if !ok {
+			return writeOrderedSchema(buf, value, subschema(s, key))
+		}
+		subschemas := subschemaList(s, key)
+
+		buf.WriteByte('[')
+		for i, item := range list {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			var itemSchema *Schema
+			if i < len(subschemas) {
+				itemSchema = subschemas[i]
+			}
+			if err := writeOrderedSchema(buf, item, itemSchema); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case "additionalItems", "additionalProperties", "unevaluatedProperties", "contains", "propertyNames", "if", "then", "else", "not":
+		return writeOrderedSchema(buf, value, subschema(s, key))
+	}
+	return writeJSON(buf, value)
+}
+
+// namedSubschema returns the subschema of the map keyword with the given name
+func namedSubschema(s *Schema, key, name string) *Schema {
+	if s == nil {
+		return nil
+	}
+	switch key {
+	case "properties":
+		return s.Properties[name]
+	case "patternProperties":
+		return s.PatternProperties[name]
+	case "dependentSchemas", "dependencies":
+		return s.DependentSchemas[name]
+	case "$defs", "definitions":
+		if subSchema, ok := s.Defs[name]; ok {
+			return subSchema
+		}
+		return s.Definitions[name]
+	}
+	return nil
+}
+
+// subschema returns the subschema of the keyword
+func subschema(s *Schema, key string) *Schema {
+	if s == nil {
+		return nil
+	}
+	var value SchemaOrBool
+	switch key {
+	case "items", "additionalItems":
+		// without prefixItems, items keeps its name
+		return s.Items
+	case "contains":
+		return s.Contains
+	case "propertyNames":
+		return s.PropertyNamesSchema
+	case "if":
+		return s.If
+	case "then":
+		return s.Then
+	case "else":
+		return s.Else
+	case "not":
+		return s.Not
+	case "additionalProperties":
+		value = s.AdditionalProperties
+	case "unevaluatedProperties":
+		value = s.UnevaluatedProperties
+	}
+	subSchema, _ := value.(*Schema)
+	return subSchema
+}
+
+// subschemaList returns the subschemas of the array keyword
+func subschemaList(s *Schema, key string) []*Schema {
+	if s == nil {
+		return nil
+	}
+	switch key {
+	case "prefixItems", "items":
+		// the array form of items is the prefixItems keyword of older drafts
+		return s.PrefixItems
+	case "allOf":
+		return s.AllOf
+	case "anyOf":
+		return s.AnyOf
+	case "oneOf":
+		return s.OneOf
+	}
+	return nil
+}
+
+// orderedKeys returns the keys of object in the given order, followed by the remaining keys sorted
+func orderedKeys(object map[string]interface{}, order []string) []string {
+	keys := make([]string, 0, len(object))
+	seen := make(map[string]bool, len(object))
+	for _, key := range order {
+		if _, ok := object[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	var rest []string
+	for key := range object {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+func writeJSON(buf *bytes.Buffer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/winterRel/helm-schema/pkg/chart"
+)
+
+// dependencyResolver finds the charts used for the dependencies of a chart
+type dependencyResolver struct {
+	byName map[string][]*Result
+	byDir  map[string]*Result
+}
+
+func newDependencyResolver(results []*Result) *dependencyResolver {
+	resolver := &dependencyResolver{
+		byName: make(map[string][]*Result),
+		byDir:  make(map[string]*Result),
+	}
+	for _, result := range results {
+		if result.Chart == nil {
+			continue
+		}
+		resolver.byName[result.Chart.Name] = append(resolver.byName[result.Chart.Name], result)
+		if !result.Archive {
+			resolver.byDir[filepath.Dir(result.ChartPath)] = result
+		}
+	}
+	return resolver
+}
+
+// resolve returns the chart used for the dependency of parent, nil if there is none.
+// Dependencies with a file:// repository are looked up in their directory or, if helm dependency build
+// packaged them, in the charts directory of the parent. Otherwise a chart with the name of the dependency
+// is used, whose version matches the (locked) version of the dependency. Charts in the charts directory
+// of the parent are preferred.
+func (r *dependencyResolver) resolve(parent *Result, dep *chart.Dependency) *Result {
+	dir, isFileDependency := fileDependencyDir(parent, dep)
+	if isFileDependency && !parent.Archive {
+		if result, ok := r.byDir[dir]; ok && result.Chart.Name == dep.Name {
+			return result
+		}
+	}
+
+	var constraint *semver.Constraints
+	if dep.Version != "" {
+		var err error
+		constraint, err = semver.NewConstraint(dep.Version)
+		if err != nil {
+			log.Warnf("Ignoring invalid version %s of dependency %s (%s): %s", dep.Version, dep.Name, parent.ChartPath, err)
+		}
+	}
+
+	chartsDir := filepath.Join(filepath.Dir(parent.ChartPath), "charts") + string(filepath.Separator)
+	var found *Result
+	for _, candidate := range r.byName[dep.Name] {
+		if candidate == parent {
+			continue
+		}
+		if constraint != nil {
+			version, err := semver.NewVersion(candidate.Chart.Version)
+			if err != nil || !constraint.Check(version) {
+				continue
+			}
+		}
+		if strings.HasPrefix(candidate.ChartPath, chartsDir) {
+			return candidate
+		}
+		if isFileDependency {
+			// any other chart with the same name is a guess
+			continue
+		}
+		if found == nil {
+			found = candidate
+		}
+	}
+	return found
+}
+
+// fileDependencyDir returns the directory of the dependency of parent, if it has a file:// repository
+func fileDependencyDir(parent *Result, dep *chart.Dependency) (string, bool) {
+	if !strings.HasPrefix(dep.Repository, "file://") {
+		return "", false
+	}
+	dir := strings.TrimPrefix(dep.Repository, "file://")
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(parent.ChartPath), dir)
+	}
+	return filepath.Clean(dir), true
+}
@@ -6,10 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -195,8 +195,10 @@ func (s *Schema) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 
-	// Unmarshal the JSON back into the map
-	if err := json.Unmarshal(aliasJSON, &data); err != nil {
+	// Unmarshal the JSON back into the map, keeping numbers as they are written
+	decoder := json.NewDecoder(bytes.NewReader(aliasJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
 		return nil, err
 	}
 
@@ -207,50 +209,74 @@ func (s *Schema) MarshalJSON() ([]byte, error) {
 
 	delete(data, "CustomAnnotations")
 
+	// use the keyword forms of the draft
+	s.draft.convertKeywords(data)
+
 	// Marshal the final map into JSON
 	return json.Marshal(data)
 }
 
 // Schema struct contains yaml tags for reading, json for writing (creating the jsonschema)
 type Schema struct {
-	AdditionalProperties SchemaOrBool           `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
-	Default              interface{}            `yaml:"default,omitempty"              json:"default,omitempty"`
-	Then                 *Schema                `yaml:"then,omitempty"                 json:"then,omitempty"`
-	PatternProperties    map[string]*Schema     `yaml:"patternProperties,omitempty"    json:"patternProperties,omitempty"`
-	Properties           map[string]*Schema     `yaml:"properties,omitempty"           json:"properties,omitempty"`
-	If                   *Schema                `yaml:"if,omitempty"                   json:"if,omitempty"`
-	Minimum              *int                   `yaml:"minimum,omitempty"              json:"minimum,omitempty"`
-	MultipleOf           *int                   `yaml:"multipleOf,omitempty"           json:"multipleOf,omitempty"`
-	ExclusiveMaximum     *int                   `yaml:"exclusiveMaximum,omitempty"     json:"exclusiveMaximum,omitempty"`
-	Items                *Schema                `yaml:"items,omitempty"                json:"items,omitempty"`
-	ExclusiveMinimum     *int                   `yaml:"exclusiveMinimum,omitempty"     json:"exclusiveMinimum,omitempty"`
-	Maximum              *int                   `yaml:"maximum,omitempty"              json:"maximum,omitempty"`
-	Else                 *Schema                `yaml:"else,omitempty"                 json:"else,omitempty"`
-	Pattern              string                 `yaml:"pattern,omitempty"              json:"pattern,omitempty"`
-	Const                interface{}            `yaml:"const,omitempty"                json:"const,omitempty"`
-	Ref                  string                 `yaml:"$ref,omitempty"                 json:"$ref,omitempty"`
-	Schema               string                 `yaml:"$schema,omitempty"              json:"$schema,omitempty"`
-	Id                   string                 `yaml:"$id,omitempty"                  json:"$id,omitempty"`
-	Format               string                 `yaml:"format,omitempty"               json:"format,omitempty"`
-	Description          string                 `yaml:"description,omitempty"          json:"description,omitempty"`
-	Title                string                 `yaml:"title,omitempty"                json:"title,omitempty"`
-	Type                 StringOrArrayOfString  `yaml:"type,omitempty"                 json:"type,omitempty"`
-	AnyOf                []*Schema              `yaml:"anyOf,omitempty"                json:"anyOf,omitempty"`
-	AllOf                []*Schema              `yaml:"allOf,omitempty"                json:"allOf,omitempty"`
-	OneOf                []*Schema              `yaml:"oneOf,omitempty"                json:"oneOf,omitempty"`
-	Not                  *Schema                `yaml:"not,omitempty"                json:"not,omitempty"`
-	Examples             []string               `yaml:"examples,omitempty"             json:"examples,omitempty"`
-	Enum                 []string               `yaml:"enum,omitempty"                 json:"enum,omitempty"`
-	HasData              bool                   `yaml:"-"                              json:"-"`
-	Deprecated           bool                   `yaml:"deprecated,omitempty"           json:"deprecated,omitempty"`
-	ReadOnly             bool                   `yaml:"readOnly,omitempty"           json:"readOnly,omitempty"`
-	WriteOnly            bool                   `yaml:"writeOnly,omitempty"           json:"writeOnly,omitempty"`
-	Required             BoolOrArrayOfString    `yaml:"required,omitempty"             json:"required,omitempty"`
-	CustomAnnotations    map[string]interface{} `yaml:"-"                              json:",omitempty"`
-	MinLength            *int                   `yaml:"minLength,omitempty"              json:"minLength,omitempty"`
-	MaxLength            *int                   `yaml:"maxLength,omitempty"              json:"maxLength,omitempty"`
-	MinItems             *int                   `yaml:"minItems,omitempty"              json:"minItems,omitempty"`
-	MaxItems             *int                   `yaml:"maxItems,omitempty"              json:"maxItems,omitempty"`
+	AdditionalProperties  SchemaOrBool           `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
+	Default               interface{}            `yaml:"default,omitempty"              json:"default,omitempty"`
+	Then                  *Schema                `yaml:"then,omitempty"                 json:"then,omitempty"`
+	PatternProperties     map[string]*Schema     `yaml:"patternProperties,omitempty"    json:"patternProperties,omitempty"`
+	Properties            map[string]*Schema     `yaml:"properties,omitempty"           json:"properties,omitempty"`
+	If                    *Schema                `yaml:"if,omitempty"                   json:"if,omitempty"`
+	Minimum               *Number                `yaml:"minimum,omitempty"              json:"minimum,omitempty"`
+	MultipleOf            *Number                `yaml:"multipleOf,omitempty"           json:"multipleOf,omitempty"`
+	ExclusiveMaximum      *Number                `yaml:"exclusiveMaximum,omitempty"     json:"exclusiveMaximum,omitempty"`
+	Items                 *Schema                `yaml:"items,omitempty"                json:"items,omitempty"`
+	ExclusiveMinimum      *Number                `yaml:"exclusiveMinimum,omitempty"     json:"exclusiveMinimum,omitempty"`
+	Maximum               *Number                `yaml:"maximum,omitempty"              json:"maximum,omitempty"`
+	Else                  *Schema                `yaml:"else,omitempty"                 json:"else,omitempty"`
+	Pattern               string                 `yaml:"pattern,omitempty"              json:"pattern,omitempty"`
+	Const                 interface{}            `yaml:"const,omitempty"                json:"const,omitempty"`
+	Ref                   string                 `yaml:"$ref,omitempty"                 json:"$ref,omitempty"`
+	Schema                string                 `yaml:"$schema,omitempty"              json:"$schema,omitempty"`
+	Id                    string                 `yaml:"$id,omitempty"                  json:"$id,omitempty"`
+	Format                string                 `yaml:"format,omitempty"               json:"format,omitempty"`
+	Description           string                 `yaml:"description,omitempty"          json:"description,omitempty"`
+	Title                 string                 `yaml:"title,omitempty"                json:"title,omitempty"`
+	Type                  StringOrArrayOfString  `yaml:"type,omitempty"                 json:"type,omitempty"`
+	AnyOf                 []*Schema              `yaml:"anyOf,omitempty"                json:"anyOf,omitempty"`
+	AllOf                 []*Schema              `yaml:"allOf,omitempty"                json:"allOf,omitempty"`
+	OneOf                 []*Schema              `yaml:"oneOf,omitempty"                json:"oneOf,omitempty"`
+	Not                   *Schema                `yaml:"not,omitempty"                json:"not,omitempty"`
+	Examples              []interface{}          `yaml:"examples,omitempty"             json:"examples,omitempty"`
+	Enum                  []interface{}          `yaml:"enum,omitempty"                 json:"enum,omitempty"`
+	HasData               bool                   `yaml:"-"                              json:"-"`
+	Deprecated            bool                   `yaml:"deprecated,omitempty"           json:"deprecated,omitempty"`
+	ReadOnly              bool                   `yaml:"readOnly,omitempty"           json:"readOnly,omitempty"`
+	WriteOnly             bool                   `yaml:"writeOnly,omitempty"           json:"writeOnly,omitempty"`
+	Required              BoolOrArrayOfString    `yaml:"required,omitempty"             json:"required,omitempty"`
+	CustomAnnotations     map[string]interface{} `yaml:"-"                              json:",omitempty"`
+	MinLength             *int                   `yaml:"minLength,omitempty"              json:"minLength,omitempty"`
+	MaxLength             *int                   `yaml:"maxLength,omitempty"              json:"maxLength,omitempty"`
+	MinItems              *int                   `yaml:"minItems,omitempty"              json:"minItems,omitempty"`
+	MaxItems              *int                   `yaml:"maxItems,omitempty"              json:"maxItems,omitempty"`
+	Definitions           map[string]*Schema     `yaml:"definitions,omitempty"          json:"definitions,omitempty"`
+	Defs                  map[string]*Schema     `yaml:"$defs,omitempty"                json:"$defs,omitempty"`
+	PrefixItems           []*Schema              `yaml:"prefixItems,omitempty"          json:"prefixItems,omitempty"`
+	DependentRequired     map[string][]string    `yaml:"dependentRequired,omitempty"    json:"dependentRequired,omitempty"`
+	DependentSchemas      map[string]*Schema     `yaml:"dependentSchemas,omitempty"     json:"dependentSchemas,omitempty"`
+	UnevaluatedProperties SchemaOrBool           `yaml:"unevaluatedProperties,omitempty" json:"unevaluatedProperties,omitempty"`
+	MinProperties         *int                   `yaml:"minProperties,omitempty"        json:"minProperties,omitempty"`
+	MaxProperties         *int                   `yaml:"maxProperties,omitempty"        json:"maxProperties,omitempty"`
+	PropertyNamesSchema   *Schema                `yaml:"propertyNames,omitempty"        json:"propertyNames,omitempty"`
+	UniqueItems           bool                   `yaml:"uniqueItems,omitempty"          json:"uniqueItems,omitempty"`
+	Contains              *Schema                `yaml:"contains,omitempty"             json:"contains,omitempty"`
+	MinContains           *int                   `yaml:"minContains,omitempty"          json:"minContains,omitempty"`
+	MaxContains           *int                   `yaml:"maxContains,omitempty"          json:"maxContains,omitempty"`
+	Comment               string                 `yaml:"$comment,omitempty"             json:"$comment,omitempty"`
+
+	// draft decides which keyword forms are used when marshaling
+	draft Draft
+	// propertyOrder contains the property names in the order of the values file
+	propertyOrder []string
+	// generated is set for properties added without a key in the values file (e.g. global)
+	generated bool
 }
 
 func NewSchema(schemaType string) *Schema {
@@ -303,6 +329,28 @@ func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 			continue
 		}
 
+		if (key == "additionalProperties" || key == "unevaluatedProperties") && valueNode.Kind == yaml.MappingNode {
+			// the schema form of the keyword, the boolean form is kept as decoded
+			subSchema := new(Schema)
+			if err := valueNode.Decode(subSchema); err != nil {
+				return err
+			}
+			if key == "additionalProperties" {
+				alias.AdditionalProperties = subSchema
+			} else {
+				alias.UnevaluatedProperties = subSchema
+			}
+			continue
+		}
+
+		if key == "dependencies" {
+			// the draft 7 form of dependentRequired and dependentSchemas
+			if err := decodeDependencies(valueNode, (*Schema)(alias)); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Unmarshal unknown fields into the CustomAnnotations map
 		if !strings.HasPrefix(key, CustomAnnotationPrefix) {
 			continue
@@ -319,52 +367,231 @@ func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// decodeDependencies adds the entries of the dependencies keyword to dependentRequired (lists of
+// property names) and dependentSchemas (schemas) of s
+func decodeDependencies(node *yaml.Node, s *Schema) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: dependencies must be a map", node.Line)
+	}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		name, valueNode := node.Content[i].Value, node.Content[i+1]
+		if valueNode.Kind == yaml.SequenceNode {
+			var properties []string
+			if err := valueNode.Decode(&properties); err != nil {
+				return err
+			}
+			if s.DependentRequired == nil {
+				s.DependentRequired = make(map[string][]string)
+			}
+			s.DependentRequired[name] = properties
+			continue
+		}
+
+		var dependentSchema Schema
+		if err := valueNode.Decode(&dependentSchema); err != nil {
+			return err
+		}
+		if s.DependentSchemas == nil {
+			s.DependentSchemas = make(map[string]*Schema)
+		}
+		s.DependentSchemas[name] = &dependentSchema
+	}
+	return nil
+}
+
+// Generated reports if the property was added automatically instead of being generated
+// from a key in the values file (e.g. global)
+func (s *Schema) Generated() bool {
+	return s.generated
+}
+
 // Set sets the HasData field to true
 func (s *Schema) Set() {
 	s.HasData = true
 }
 
-// DisableRequiredProperties sets disables all required fields
-func (s *Schema) DisableRequiredProperties() {
-	s.Required = NewBoolOrArrayOfString([]string{}, false)
-	for _, v := range s.Properties {
-		v.DisableRequiredProperties()
-	}
-	if s.Items != nil {
-		s.Items.DisableRequiredProperties()
+// Clone returns a deep copy of the schema and all its subschemas
+func (s *Schema) Clone() *Schema {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+
+	clone.Then = s.Then.Clone()
+	clone.If = s.If.Clone()
+	clone.Else = s.Else.Clone()
+	clone.Not = s.Not.Clone()
+	clone.Items = s.Items.Clone()
+	clone.Contains = s.Contains.Clone()
+	clone.PropertyNamesSchema = s.PropertyNamesSchema.Clone()
+	clone.Properties = cloneSchemaMap(s.Properties)
+	clone.PatternProperties = cloneSchemaMap(s.PatternProperties)
+	clone.Definitions = cloneSchemaMap(s.Definitions)
+	clone.Defs = cloneSchemaMap(s.Defs)
+	clone.DependentSchemas = cloneSchemaMap(s.DependentSchemas)
+	clone.AnyOf = cloneSchemaSlice(s.AnyOf)
+	clone.AllOf = cloneSchemaSlice(s.AllOf)
+	clone.OneOf = cloneSchemaSlice(s.OneOf)
+	clone.PrefixItems = cloneSchemaSlice(s.PrefixItems)
+
+	if subSchema, ok := s.AdditionalProperties.(*Schema); ok {
+		clone.AdditionalProperties = subSchema.Clone()
+	}
+	if subSchema, ok := s.UnevaluatedProperties.(*Schema); ok {
+		clone.UnevaluatedProperties = subSchema.Clone()
+	}
+	if s.DependentRequired != nil {
+		clone.DependentRequired = make(map[string][]string, len(s.DependentRequired))
+		for k, v := range s.DependentRequired {
+			clone.DependentRequired[k] = append([]string(nil), v...)
+		}
 	}
 
-	if s.AnyOf != nil {
-		for _, v := range s.AnyOf {
-			v.DisableRequiredProperties()
+	clone.Type = append(StringOrArrayOfString(nil), s.Type...)
+	clone.Required.Strings = append([]string(nil), s.Required.Strings...)
+	clone.Examples = append([]interface{}(nil), s.Examples...)
+	clone.Enum = append([]interface{}(nil), s.Enum...)
+	clone.propertyOrder = append([]string(nil), s.propertyOrder...)
+
+	if s.CustomAnnotations != nil {
+		clone.CustomAnnotations = make(map[string]interface{}, len(s.CustomAnnotations))
+		for k, v := range s.CustomAnnotations {
+			clone.CustomAnnotations[k] = v
 		}
 	}
-	if s.OneOf != nil {
-		for _, v := range s.OneOf {
-			v.DisableRequiredProperties()
+
+	return &clone
+}
+
+func cloneSchemaMap(m map[string]*Schema) map[string]*Schema {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]*Schema, len(m))
+	for k, v := range m {
+		clone[k] = v.Clone()
+	}
+	return clone
+}
+
+func cloneSchemaSlice(l []*Schema) []*Schema {
+	if l == nil {
+		return nil
+	}
+	clone := make([]*Schema, len(l))
+	for i, v := range l {
+		clone[i] = v.Clone()
+	}
+	return clone
+}
+
+// subschemas returns all direct subschemas of the schema
+func (s *Schema) subschemas() []*Schema {
+	var result []*Schema
+	s.eachSubschema(func(_, _ string, subSchema *Schema) {
+		result = append(result, subSchema)
+	})
+	return result
+}
+
+// eachSubschema calls fn with all direct subschemas of the schema. The keyword is the one the schema
+// is marshaled with in its draft and key is the property name or index within the keyword, if any.
+func (s *Schema) eachSubschema(fn func(keyword, key string, subSchema *Schema)) {
+	// see Draft.convertKeywords
+	itemsKeyword, prefixItemsKeyword := "items", "prefixItems"
+	if s.draft != Draft2020 && len(s.PrefixItems) > 0 {
+		itemsKeyword, prefixItemsKeyword = "additionalItems", "items"
+	}
+	defsKeyword, dependentSchemasKeyword := "$defs", "dependentSchemas"
+	if s.draft == Draft7 {
+		defsKeyword, dependentSchemasKeyword = "definitions", "dependencies"
+	}
+
+	for _, sub := range []struct {
+		keyword string
+		schema  *Schema
+	}{
+		{itemsKeyword, s.Items},
+		{"contains", s.Contains},
+		{"propertyNames", s.PropertyNamesSchema},
+		{"if", s.If},
+		{"then", s.Then},
+		{"else", s.Else},
+		{"not", s.Not},
+	} {
+		if sub.schema != nil {
+			fn(sub.keyword, "", sub.schema)
 		}
 	}
-	if s.AllOf != nil {
-		for _, v := range s.AllOf {
-			v.DisableRequiredProperties()
+	for _, sub := range []struct {
+		keyword string
+		schema  SchemaOrBool
+	}{
+		{"additionalProperties", s.AdditionalProperties},
+		{"unevaluatedProperties", s.UnevaluatedProperties},
+	} {
+		if subSchema, ok := sub.schema.(*Schema); ok && subSchema != nil {
+			fn(sub.keyword, "", subSchema)
 		}
 	}
-	if s.If != nil {
-		s.If.DisableRequiredProperties()
+	for _, sub := range []struct {
+		keyword string
+		schemas []*Schema
+	}{
+		{"anyOf", s.AnyOf},
+		{"allOf", s.AllOf},
+		{"oneOf", s.OneOf},
+		{prefixItemsKeyword, s.PrefixItems},
+	} {
+		for i, subSchema := range sub.schemas {
+			fn(sub.keyword, strconv.Itoa(i), subSchema)
+		}
 	}
-	if s.Else != nil {
-		s.Else.DisableRequiredProperties()
+	for _, name := range s.PropertyNames() {
+		fn("properties", name, s.Properties[name])
+	}
+	for _, sub := range []struct {
+		keyword string
+		schemas map[string]*Schema
+	}{
+		{"patternProperties", s.PatternProperties},
+		{defsKeyword, s.Definitions},
+		{defsKeyword, s.Defs},
+		{dependentSchemasKeyword, s.DependentSchemas},
+	} {
+		keys := make([]string, 0, len(sub.schemas))
+		for key := range sub.schemas {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fn(sub.keyword, key, sub.schemas[key])
+		}
 	}
-	if s.Then != nil {
-		s.Then.DisableRequiredProperties()
+}
+
+// SetDraft sets the draft whose keyword forms are used when marshaling the schema and all its subschemas
+func (s *Schema) SetDraft(draft Draft) {
+	s.draft = draft
+	for _, subSchema := range s.subschemas() {
+		subSchema.SetDraft(draft)
 	}
-	if s.Not != nil {
-		s.Not.DisableRequiredProperties()
+}
+
+// DisableRequiredProperties sets disables all required fields
+func (s *Schema) DisableRequiredProperties() {
+	s.Required = NewBoolOrArrayOfString([]string{}, false)
+	for _, subSchema := range s.subschemas() {
+		subSchema.DisableRequiredProperties()
 	}
 }
 
 // ToJson converts the data to raw json
 func (s Schema) ToJson() ([]byte, error) {
+	if s.Schema != "" {
+		// the root schema decides which draft is used
+		s.SetDraft(draftFromURI(s.Schema))
+	}
 	res, err := json.MarshalIndent(&s, "", "  ")
 	if err != nil {
 		return nil, err
@@ -379,13 +606,23 @@ func (s Schema) Validate() error {
 		return err
 	}
 
-	c := jsonschema.NewCompiler()
-
-	if err := c.AddResource("schema.json", bytes.NewReader(jsonStr)); err != nil {
-		// if _, err := jsonschema.CompileString("schema.json", string(jsonStr)); err != nil {
+	// Check the schema against the meta-schema of the draft
+	metaSchema, err := s.draft.metaSchema()
+	if err != nil {
+		return err
+	}
+	doc, err := decodeJSON(jsonStr)
+	if err != nil {
+		return err
+	}
+	if err := metaSchema.Validate(doc); err != nil {
 		return err
 	}
 
+	if s.draft == Draft7 && s.UnevaluatedProperties != nil {
+		return fmt.Errorf("unevaluatedProperties requires jsonschema draft %s or newer", Draft2019)
+	}
+
 	// Check if type is valid
 	if err := s.Type.Validate(); err != nil {
 		return err
@@ -411,9 +648,23 @@ func (s Schema) Validate() error {
 		return errors.New("cant use format and pattern option at the same time")
 	}
 
+	// values not matching nested schemas are reported after all other problems
+	var invalidValue error
+	validateSubschema := func(subSchema *Schema) error {
+		err := subSchema.Validate()
+		var invalidValueErr *InvalidValueError
+		if errors.As(err, &invalidValueErr) {
+			if invalidValue == nil {
+				invalidValue = err
+			}
+			return nil
+		}
+		return err
+	}
+
 	// Validate nested Items schema
 	if s.Items != nil {
-		if err := s.Items.Validate(); err != nil {
+		if err := validateSubschema(s.Items); err != nil {
 			return err
 		}
 	}
@@ -431,12 +682,41 @@ func (s Schema) Validate() error {
 		return errors.New("minItems cant be greater than maxItems")
 	}
 
-	if s.Const != nil && !s.Type.IsEmpty() {
-		return errors.New("if your are using const, you can't use type")
+	if (s.UniqueItems || s.Contains != nil || s.MinContains != nil || s.MaxContains != nil) && !s.Type.IsEmpty() && !s.Type.Matches("array") {
+		return fmt.Errorf("cant use uniqueItems, contains, minContains or maxContains if type is %s. Use type=array", s.Type)
 	}
 
-	if s.Enum != nil && !s.Type.IsEmpty() {
-		return errors.New("if your are using enum, you can't use type")
+	if (s.MinContains != nil || s.MaxContains != nil) && s.Contains == nil {
+		return errors.New("minContains and maxContains can only be used with contains")
+	}
+
+	if s.draft == Draft7 && (s.MinContains != nil || s.MaxContains != nil) {
+		return fmt.Errorf("minContains and maxContains require jsonschema draft %s or newer", Draft2019)
+	}
+
+	if (s.MinContains != nil && s.MaxContains != nil) && *s.MaxContains < *s.MinContains {
+		return errors.New("minContains cant be greater than maxContains")
+	}
+
+	if (s.MinProperties != nil || s.MaxProperties != nil || s.PropertyNamesSchema != nil) && !s.Type.IsEmpty() && !s.Type.Matches("object") {
+		return fmt.Errorf("cant use minProperties, maxProperties or propertyNames if type is %s. Use type=object", s.Type)
+	}
+
+	if (s.MinProperties != nil && s.MaxProperties != nil) && *s.MaxProperties < *s.MinProperties {
+		return errors.New("minProperties cant be greater than maxProperties")
+	}
+
+	// Validate nested contains and propertyNames schemas
+	for _, subSchema := range []*Schema{s.Contains, s.PropertyNamesSchema} {
+		if subSchema != nil {
+			if err := validateSubschema(subSchema); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.Const != nil && !s.Type.IsEmpty() {
+		return errors.New("if your are using const, you can't use type")
 	}
 
 	// Check if format is valid
@@ -480,7 +760,7 @@ func (s Schema) Validate() error {
 	if s.MultipleOf != nil && !s.Type.IsEmpty() && !s.Type.Matches("number") && !s.Type.Matches("integer") {
 		return fmt.Errorf("if you use multiple, you cant use type=%s", s.Type)
 	}
-	if s.MultipleOf != nil && *s.MultipleOf <= 0 {
+	if s.MultipleOf != nil && s.MultipleOf.Rat().Sign() <= 0 {
 		return errors.New("multiple option must be greater than 0")
 	}
 	if s.Minimum != nil && s.ExclusiveMinimum != nil {
@@ -489,7 +769,31 @@ func (s Schema) Validate() error {
 	if s.Maximum != nil && s.ExclusiveMaximum != nil {
 		return errors.New("you cant set minimum and exclusiveMaximum")
 	}
-	return nil
+
+	// Check if the lower bound is below the upper bound, exclusive bounds can't be equal
+	lower, lowerName := s.Minimum, "minimum"
+	if s.ExclusiveMinimum != nil {
+		lower, lowerName = s.ExclusiveMinimum, "exclusiveMinimum"
+	}
+	upper, upperName := s.Maximum, "maximum"
+	if s.ExclusiveMaximum != nil {
+		upper, upperName = s.ExclusiveMaximum, "exclusiveMaximum"
+	}
+	if lower != nil && upper != nil {
+		relation := "less than or equal to"
+		if s.ExclusiveMinimum != nil || s.ExclusiveMaximum != nil {
+			relation = "less than"
+		}
+		if cmp := lower.Cmp(*upper); cmp > 0 || (cmp == 0 && relation == "less than") {
+			return fmt.Errorf("%s %s must be %s %s %s", lowerName, lower, relation, upperName, upper)
+		}
+	}
+
+	// Check the values of enum and examples last, the only problem which may be just a warning
+	if err := s.validateEnumAndExamples(); err != nil {
+		return err
+	}
+	return invalidValue
 }
 
 var possibleSkipFields = []string{"title", "description", "required", "default", "additionalProperties"}
@@ -585,10 +889,16 @@ func FixRequiredProperties(schema *Schema) error {
 		FixRequiredProperties(schema.Items)
 	}
 
-	if schema.AdditionalProperties != nil {
-		if subSchema, ok := schema.AdditionalProperties.(Schema); ok {
-			FixRequiredProperties(&subSchema)
-		}
+	if schema.Contains != nil {
+		FixRequiredProperties(schema.Contains)
+	}
+
+	if schema.PropertyNamesSchema != nil {
+		FixRequiredProperties(schema.PropertyNamesSchema)
+	}
+
+	if subSchema, ok := schema.AdditionalProperties.(*Schema); ok && subSchema != nil {
+		FixRequiredProperties(subSchema)
 	}
 
 	if len(schema.AnyOf) > 0 {
@@ -613,6 +923,20 @@ func FixRequiredProperties(schema *Schema) error {
 		FixRequiredProperties(schema.Not)
 	}
 
+	for _, subSchema := range schema.PrefixItems {
+		FixRequiredProperties(subSchema)
+	}
+
+	if subSchema, ok := schema.UnevaluatedProperties.(*Schema); ok && subSchema != nil {
+		FixRequiredProperties(subSchema)
+	}
+
+	for _, subSchemas := range []map[string]*Schema{schema.Definitions, schema.Defs, schema.DependentSchemas} {
+		for _, subSchema := range subSchemas {
+			FixRequiredProperties(subSchema)
+		}
+	}
+
 	return nil
 }
 
@@ -652,74 +976,109 @@ func GetSchemaFromComment(comment string) (Schema, string, error) {
 	return result, strings.Join(description, "\n"), nil
 }
 
-// YamlToSchema recursevly parses the given yaml.Node and creates a jsonschema from it
+// YamlToSchema recursevly parses the given yaml.Node and creates a jsonschema from it.
+// Problems with single keys don't abort the generation, they are collected and returned
+// as *ValuesError.
 func YamlToSchema(
 	valuesPath string,
 	node *yaml.Node,
-	keepFullComment bool,
-	helmDocsCompatibilityMode bool,
-	dontRemoveHelmDocsPrefix bool,
-	skipAutoGeneration *SkipAutoGenerationConfig,
+	options *GeneratorOptions,
 	parentRequiredProperties *[]string,
-) *Schema {
+) (*Schema, []error) {
+	converter := &yamlConverter{valuesPath: valuesPath, options: options}
+	schema := converter.convert(node, "", parentRequiredProperties)
+	return schema, converter.errors
+}
+
+type yamlConverter struct {
+	valuesPath string
+	options    *GeneratorOptions
+	errors     []error
+}
+
+func (c *yamlConverter) addError(keyPath string, node *yaml.Node, err error) {
+	valuesErr := &ValuesError{ValuesPath: c.valuesPath, KeyPath: keyPath, Err: err}
+	if node != nil {
+		valuesErr.Line = node.Line
+		valuesErr.Column = node.Column
+	}
+	c.errors = append(c.errors, valuesErr)
+}
+
+func (c *yamlConverter) convert(node *yaml.Node, keyPath string, parentRequiredProperties *[]string) *Schema {
+	skipAutoGeneration := &c.options.SkipAutoGeneration
 	schema := NewSchema("object")
 	switch node.Kind {
 	case yaml.DocumentNode:
 		if len(node.Content) != 1 {
-			log.Fatalf("Strange yaml document found:\n%v\n", node.Content[:])
+			c.addError(keyPath, node, fmt.Errorf("strange yaml document found: %v", node.Content[:]))
+			return schema
 		}
 
-		schema.Schema = "http://json-schema.org/draft-07/schema#"
-		schema.Properties = YamlToSchema(
-			valuesPath,
+		schema.Schema = c.options.Draft.URI()
+		valuesSchema := c.convert(
 			node.Content[0],
-			keepFullComment,
-			helmDocsCompatibilityMode,
-			dontRemoveHelmDocsPrefix,
-			skipAutoGeneration,
+			keyPath,
 			&schema.Required.Strings,
-		).Properties
-
-		// 不生成Global
-		// if _, ok := schema.Properties["global"]; !ok {
-		// 	// global key must be present, otherwise helm lint will fail
-		// 	if schema.Properties == nil {
-		// 		schema.Properties = make(map[string]*Schema)
-		// 	}
-		// 	schema.Properties["global"] = NewSchema(
-		// 		"object",
-		// 	)
-		// 	if !skipAutoGeneration.Title {
-		// 		schema.Properties["global"].Title = "global"
-		// 	}
-		// 	if !skipAutoGeneration.Description {
-		// 		schema.Properties["global"].Description = "Global values are values that can be accessed from any chart or subchart by exactly the same name."
-		// 	}
-		// }
+		)
+		schema.Properties = valuesSchema.Properties
+		schema.propertyOrder = valuesSchema.propertyOrder
+
+		if _, ok := schema.Properties["global"]; !ok {
+			// global key must be present, otherwise helm lint will fail
+			if schema.Properties == nil {
+				schema.Properties = make(map[string]*Schema)
+			}
+			schema.Properties["global"] = NewSchema(
+				"object",
+			)
+			schema.Properties["global"].generated = true
+			if !skipAutoGeneration.Title {
+				schema.Properties["global"].Title = "global"
+			}
+			if !skipAutoGeneration.Description {
+				schema.Properties["global"].Description = "Global values are values that can be accessed from any chart or subchart by exactly the same name."
+			}
+		}
 		// always disable on top level
 		if !skipAutoGeneration.AdditionalProperties {
 			schema.AdditionalProperties = new(bool)
 		}
+
+		schema.SetDraft(c.options.Draft)
 	case yaml.MappingNode:
-		for i := 0; i < len(node.Content); i += 2 {
-			keyNode := node.Content[i]
-			valueNode := node.Content[i+1]
+		content, err := expandMergeKeys(node)
+		if err != nil {
+			errNode := node
+			var mergeErr *mergeKeyError
+			if errors.As(err, &mergeErr) {
+				errNode = mergeErr.keyNode
+			}
+			c.addError(keyPath, errNode, err)
+			return schema
+		}
+		for i := 0; i < len(content); i += 2 {
+			keyNode := content[i]
+			valueNode := content[i+1]
+			keyNodePath := joinKeyPath(keyPath, keyNode.Value)
 
 			if valueNode.Kind == yaml.AliasNode {
 				valueNode = valueNode.Alias
 			}
 
 			comment := keyNode.HeadComment
-			if !keepFullComment {
+			if !c.options.KeepFullComment {
 				leadingCommentsRemover := regexp.MustCompile(`(?s)(?m)(?:.*\n{2,})+`)
 				comment = leadingCommentsRemover.ReplaceAllString(comment, "")
 			}
 
 			keyNodeSchema, description, err := GetSchemaFromComment(comment)
 			if err != nil {
-				log.Fatalf("Error while parsing comment of key %s: %v", keyNode.Value, err)
+				c.addError(keyNodePath, keyNode, fmt.Errorf("error while parsing comment: %w", err))
+				continue
 			}
-			if helmDocsCompatibilityMode {
+			keyNodeSchema.SetDraft(c.options.Draft)
+			if c.options.HelmDocsCompatibilityMode {
 				_, helmDocsValue := helm.ParseComment(strings.Split(keyNode.HeadComment, "\n"))
 				if helmDocsValue.Default != "" {
 					keyNodeSchema.Set()
@@ -740,7 +1099,7 @@ func YamlToSchema(
 				}
 			}
 
-			if !dontRemoveHelmDocsPrefix {
+			if !c.options.DontRemoveHelmDocsPrefix {
 				// remove all lines containing helm-docs @tags, like @ignored, or one of those:
 				// https://github.com/norwoodj/helm-docs/blob/v1.14.2/pkg/helm/chart_info.go#L18-L24
 				helmDocsTagsRemover := regexp.MustCompile(`(?ms)(\r\n|\r|\n)?\s*@\w+(\s+--\s)?[^\n\r]*`)
@@ -757,40 +1116,15 @@ func YamlToSchema(
 				} else {
 					// Check if Ref is a relative file to the values file
 					refParts := strings.Split(keyNodeSchema.Ref, "#")
-					if relFilePath, err := util.IsRelativeFile(valuesPath, refParts[0]); err == nil {
-						var relSchema Schema
-						file, err := os.Open(relFilePath)
-						if err == nil {
-							byteValue, _ := io.ReadAll(file)
-
-							if len(refParts) > 1 {
-								// Found json-pointer
-								var obj interface{}
-								json.Unmarshal(byteValue, &obj)
-								jsonPointerResultRaw, err := jsonpointer.Get(obj, refParts[1])
-								if err != nil {
-									log.Fatal(err)
-								}
-								jsonPointerResultMarshaled, err := json.Marshal(jsonPointerResultRaw)
-								if err != nil {
-									log.Fatal(err)
-								}
-								err = json.Unmarshal(jsonPointerResultMarshaled, &relSchema)
-								if err != nil {
-									log.Fatal(err)
-								}
-							} else {
-								// No json-pointer
-								err = json.Unmarshal(byteValue, &relSchema)
-								if err != nil {
-									log.Fatal(err)
-								}
-							}
-							keyNodeSchema = relSchema
-							keyNodeSchema.HasData = true
-						} else {
-							log.Fatal(err)
+					if relFilePath, err := util.IsRelativeFile(c.valuesPath, refParts[0]); err == nil {
+						relSchema, err := readRelativeRef(relFilePath, refParts[1:])
+						if err != nil {
+							c.addError(keyNodePath, keyNode, fmt.Errorf("error while resolving $ref %s: %w", keyNodeSchema.Ref, err))
+							continue
 						}
+						keyNodeSchema = relSchema
+						keyNodeSchema.HasData = true
+						keyNodeSchema.SetDraft(c.options.Draft)
 					} else {
 						log.Debug(err)
 					}
@@ -799,16 +1133,18 @@ func YamlToSchema(
 
 			if keyNodeSchema.HasData {
 				if err := keyNodeSchema.Validate(); err != nil {
-					log.Fatalf(
-						"Error while validating jsonschema of key %s: %v",
-						keyNode.Value,
-						err,
-					)
+					var invalidValueErr *InvalidValueError
+					if !c.options.WarnInvalidDefaults || !errors.As(err, &invalidValueErr) {
+						c.addError(keyNodePath, keyNode, fmt.Errorf("error while validating jsonschema: %w", err))
+						continue
+					}
+					log.Warnln(&ValuesError{ValuesPath: c.valuesPath, KeyPath: keyNodePath, Line: keyNode.Line, Column: keyNode.Column, Err: err})
 				}
 			} else {
 				nodeType, err := typeFromTag(valueNode.Tag)
 				if err != nil {
-					log.Fatal(err)
+					c.addError(keyNodePath, valueNode, err)
+					continue
 				}
 				keyNodeSchema.Type = nodeType
 			}
@@ -838,36 +1174,36 @@ func YamlToSchema(
 					keyNodeSchema.Description = description
 				}
 
-				// If no default value was set, use the values node value as default
-				if !skipAutoGeneration.Default && keyNodeSchema.Default == nil && valueNode.Kind == yaml.ScalarNode {
+				// If no default value was set, use the values node value as default (null can't be expressed)
+				if !skipAutoGeneration.Default && keyNodeSchema.Default == nil && valueNode.Kind == yaml.ScalarNode && valueNode.ShortTag() != nullTag {
 					keyNodeSchema.Default = castNodeValueByType(valueNode.Value, keyNodeSchema.Type)
 				}
 
 				// If the value is another map and no properties are set, get them from default values
 				if valueNode.Kind == yaml.MappingNode && keyNodeSchema.Properties == nil {
-					keyNodeSchema.Properties = YamlToSchema(
-						valuesPath,
+					valuesSchema := c.convert(
 						valueNode,
-						keepFullComment,
-						helmDocsCompatibilityMode,
-						dontRemoveHelmDocsPrefix,
-						skipAutoGeneration,
+						keyNodePath,
 						&keyNodeSchema.Required.Strings,
-					).Properties
+					)
+					keyNodeSchema.Properties = valuesSchema.Properties
+					keyNodeSchema.propertyOrder = valuesSchema.propertyOrder
 				} else if valueNode.Kind == yaml.SequenceNode && keyNodeSchema.Items == nil {
 					// If the value is a sequence, but no items are predefined
-					seqSchema := NewSchema("")
+					var itemSchemas []*Schema
 
-					for _, itemNode := range valueNode.Content {
+					for itemIndex, itemNode := range valueNode.Content {
+						itemPath := fmt.Sprintf("%s[%d]", keyNodePath, itemIndex)
 						if itemNode.Kind == yaml.ScalarNode {
 							itemNodeType, err := typeFromTag(itemNode.Tag)
 							if err != nil {
-								log.Fatal(err)
+								c.addError(itemPath, itemNode, err)
+								continue
 							}
-							seqSchema.AnyOf = append(seqSchema.AnyOf, NewSchema(itemNodeType[0]))
+							itemSchemas = append(itemSchemas, NewSchema(itemNodeType[0]))
 						} else {
 							itemRequiredProperties := []string{}
-							itemSchema := YamlToSchema(valuesPath, itemNode, keepFullComment, helmDocsCompatibilityMode, dontRemoveHelmDocsPrefix, skipAutoGeneration, &itemRequiredProperties)
+							itemSchema := c.convert(itemNode, itemPath, &itemRequiredProperties)
 
 							for _, req := range itemRequiredProperties {
 								itemSchema.Required.Strings = append(itemSchema.Required.Strings, req)
@@ -877,10 +1213,10 @@ func YamlToSchema(
 								itemSchema.AdditionalProperties = new(bool)
 							}
 
-							seqSchema.AnyOf = append(seqSchema.AnyOf, itemSchema)
+							itemSchemas = append(itemSchemas, itemSchema)
 						}
 					}
-					keyNodeSchema.Items = seqSchema
+					keyNodeSchema.Items = inferItemsSchema(itemSchemas)
 
 					// Because the `required` field isn't valid jsonschema (but just a helper boolean)
 					// we must convert them to valid requiredProperties fields
@@ -892,12 +1228,117 @@ func YamlToSchema(
 				schema.Properties = make(map[string]*Schema)
 			}
 			schema.Properties[keyNode.Value] = &keyNodeSchema
+			schema.propertyOrder = append(schema.propertyOrder, keyNode.Value)
 		}
 	}
 
 	return schema
 }
 
+// readRelativeRef reads the schema from the given file.
+// If a json pointer is given, only the referenced part of the file is used.
+func readRelativeRef(relFilePath string, pointer []string) (Schema, error) {
+	var relSchema Schema
+
+	byteValue, err := os.ReadFile(relFilePath)
+	if err != nil {
+		return relSchema, err
+	}
+
+	if len(pointer) > 0 {
+		// Found json-pointer
+		obj, err := decodeJSON(byteValue)
+		if err != nil {
+			return relSchema, err
+		}
+		jsonPointerResultRaw, err := jsonpointer.Get(obj, pointer[0])
+		if err != nil {
+			return relSchema, err
+		}
+		byteValue, err = json.Marshal(jsonPointerResultRaw)
+		if err != nil {
+			return relSchema, err
+		}
+	}
+
+	err = json.Unmarshal(byteValue, &relSchema)
+	return relSchema, err
+}
+
+// joinKeyPath appends the key to the dot separated path of yaml keys
+func joinKeyPath(keyPath, key string) string {
+	if keyPath == "" {
+		return key
+	}
+	return keyPath + "." + key
+}
+
+// mergeKeyError is returned if a merge key doesn't reference a map or a list of maps
+type mergeKeyError struct {
+	keyNode *yaml.Node
+}
+
+func (e *mergeKeyError) Error() string {
+	return "merge key (<<) must reference a map or a list of maps"
+}
+
+// isMergeKey checks if the node is a yaml merge key (<<)
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!merge" && node.Value == "<<"
+}
+
+// expandMergeKeys returns the key and value nodes of the mapping with all merge keys (<<) expanded
+// like the yaml merge spec describes: keys of the mapping itself take precedence over merged keys
+// and keys of earlier merged mappings take precedence over the ones of later mappings.
+func expandMergeKeys(node *yaml.Node) ([]*yaml.Node, error) {
+	localKeys := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isMergeKey(node.Content[i]) {
+			localKeys[node.Content[i].Value] = true
+		}
+	}
+
+	var content []*yaml.Node
+	mergedKeys := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if !isMergeKey(keyNode) {
+			content = append(content, keyNode, valueNode)
+			continue
+		}
+
+		if valueNode.Kind == yaml.AliasNode {
+			valueNode = valueNode.Alias
+		}
+		sources := []*yaml.Node{valueNode}
+		if valueNode.Kind == yaml.SequenceNode {
+			sources = valueNode.Content
+		}
+
+		for _, source := range sources {
+			if source.Kind == yaml.AliasNode {
+				source = source.Alias
+			}
+			if source.Kind != yaml.MappingNode {
+				return nil, &mergeKeyError{keyNode: keyNode}
+			}
+			sourceContent, err := expandMergeKeys(source)
+			if err != nil {
+				return nil, err
+			}
+			for j := 0; j < len(sourceContent); j += 2 {
+				key := sourceContent[j].Value
+				if localKeys[key] || mergedKeys[key] {
+					continue
+				}
+				mergedKeys[key] = true
+				content = append(content, sourceContent[j], sourceContent[j+1])
+			}
+		}
+	}
+	return content, nil
+}
+
 func helmDocsTypeToSchemaType(helmDocsType string) (string, error) {
 	switch helmDocsType {
 	case "int":
@@ -948,6 +1389,59 @@ func castNodeValueByType(rawValue string, fieldType StringOrArrayOfString) any {
 	return rawValue
 }
 
+// validateEnumAndExamples checks that every enum value matches the other keywords of the schema
+// and every example matches the whole schema
+func (s Schema) validateEnumAndExamples() error {
+	withoutEnum := s
+	withoutEnum.Enum = nil
+	for _, check := range []struct {
+		keyword string
+		values  []interface{}
+		schema  Schema
+	}{
+		{"enum", s.Enum, withoutEnum},
+		{"examples", s.Examples, s},
+	} {
+		if len(check.values) == 0 {
+			continue
+		}
+
+		jsonStr, err := check.schema.ToJson()
+		if err != nil {
+			return err
+		}
+		c := jsonschema.NewCompiler()
+		c.Draft = s.draft.compilerDraft()
+		if err := c.AddResource("schema.json", bytes.NewReader(jsonStr)); err != nil {
+			return err
+		}
+		compiled, err := c.Compile("schema.json")
+		if err != nil {
+			// references to definitions outside of this schema can't be resolved here
+			log.Debugf("Skipping the validation of %s: %v", check.keyword, err)
+			continue
+		}
+
+		for _, value := range check.values {
+			if err := validateValue(compiled, check.keyword, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeJSON decodes the json document, keeping the precision of numbers
+func decodeJSON(data []byte) (interface{}, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
 // Contains reports whether v is present in s.
 func Contains[S ~[]E, E comparable](s S, v E) bool {
 	return Index(s, v) >= 0
@@ -1,7 +1,11 @@
 package schema
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/magiconair/properties/assert"
@@ -23,6 +27,60 @@ func TestValidate(t *testing.T) {
 		{
 			comment: `
 # @schema
+# multipleOf: 0.1
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# multipleOf: -0.5
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 0.5
+# maximum: 1.5
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 2
+# maximum: 1.5
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 1
+# maximum: 1
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# exclusiveMinimum: 1
+# maximum: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 0.25
+# exclusiveMaximum: 0.5
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
 # type: doesnotexist
 # @schema`,
 			expectedValid: false,
@@ -168,6 +226,148 @@ func TestValidate(t *testing.T) {
 # @schema
 # type: string
 # minItems: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: object
+# minProperties: 1
+# maxProperties: 2
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# minProperties: 2
+# maxProperties: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: array
+# propertyNames:
+#   pattern: ^[a-z]+$
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: array
+# uniqueItems: true
+# contains:
+#   type: string
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: object
+# uniqueItems: true
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: array
+# contains:
+#   minLength: 2
+#   maxLength: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: array
+# minContains: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: array
+# contains:
+#   type: string
+# minContains: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# $comment: only for maintainers
+# type: string
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: integer
+# enum: [1, 2, 3]
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: string
+# enum: [foo, 1]
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# enum: [true, null, 1.5, foo]
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: integer
+# minimum: 2
+# enum: [1, 2]
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: object
+# examples:
+#   - name: foo
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: object
+# properties:
+#   name:
+#     type: string
+# examples:
+#   - name: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: string
+# format: ipv4
+# examples: [not-an-ip]
 # @schema`,
 			expectedValid: false,
 		},
@@ -210,3 +410,352 @@ x-custom-foo: bar
 	assert.Equal(t, schema.Type, StringOrArrayOfString{"string"})
 	assert.Equal(t, schema.CustomAnnotations["x-custom-foo"], "bar")
 }
+
+func TestYamlToSchemaErrors(t *testing.T) {
+	values := `
+# @schema
+# type: doesnotexist
+# @schema
+foo: bar
+nested:
+  # @schema
+  # minLength: 2
+  # maxLength: 1
+  # @schema
+  baz: qux
+ok: true
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(values), &node); err != nil {
+		t.Fatalf("Error while reading test data: %v", err)
+	}
+
+	options := DefaultGeneratorOptions()
+	schema, errs := YamlToSchema("values.yaml", &node, &options, nil)
+	if len(errs) != 2 {
+		t.Fatalf("Expected to find 2 errors, but got %d: %v", len(errs), errs)
+	}
+
+	expected := []struct {
+		keyPath string
+		line    int
+	}{
+		{keyPath: "foo", line: 5},
+		{keyPath: "nested.baz", line: 11},
+	}
+	for i, exp := range expected {
+		var valuesErr *ValuesError
+		if !errors.As(errs[i], &valuesErr) {
+			t.Fatalf("Expected a ValuesError, but got %T", errs[i])
+		}
+		if valuesErr.ValuesPath != "values.yaml" || valuesErr.KeyPath != exp.keyPath || valuesErr.Line != exp.line {
+			t.Errorf("Expected error at values.yaml:%d for key %s, but got %v", exp.line, exp.keyPath, valuesErr)
+		}
+	}
+
+	// the other keys are still processed
+	if _, ok := schema.Properties["ok"]; !ok {
+		t.Errorf("Expected the key ok to be part of the schema, but got %v", schema.Properties)
+	}
+}
+
+func TestDraftKeywords(t *testing.T) {
+	yamlData := `
+$defs:
+  port:
+    type: integer
+prefixItems:
+  - type: string
+items:
+  type: integer
+dependentRequired:
+  foo: [bar]
+properties:
+  http:
+    $ref: "#/$defs/port"
+`
+	tests := []struct {
+		draft    Draft
+		expected string
+	}{
+		{
+			draft:    Draft7,
+			expected: `{"$schema":"http://json-schema.org/draft-07/schema#","additionalItems":{"required":[],"type":"integer"},"definitions":{"port":{"required":[],"type":"integer"}},"dependencies":{"foo":["bar"]},"items":[{"required":[],"type":"string"}],"properties":{"http":{"$ref":"#/definitions/port","required":[]}},"required":[]}`,
+		},
+		{
+			draft:    Draft2019,
+			expected: `{"$defs":{"port":{"required":[],"type":"integer"}},"$schema":"https://json-schema.org/draft/2019-09/schema","additionalItems":{"required":[],"type":"integer"},"dependentRequired":{"foo":["bar"]},"items":[{"required":[],"type":"string"}],"properties":{"http":{"$ref":"#/$defs/port","required":[]}},"required":[]}`,
+		},
+		{
+			draft:    Draft2020,
+			expected: `{"$defs":{"port":{"required":[],"type":"integer"}},"$schema":"https://json-schema.org/draft/2020-12/schema","dependentRequired":{"foo":["bar"]},"items":{"required":[],"type":"integer"},"prefixItems":[{"required":[],"type":"string"}],"properties":{"http":{"$ref":"#/$defs/port","required":[]}},"required":[]}`,
+		},
+	}
+
+	for _, test := range tests {
+		var schema Schema
+		if err := yaml.Unmarshal([]byte(yamlData), &schema); err != nil {
+			t.Fatalf("Error while reading test data: %v", err)
+		}
+		schema.Schema = test.draft.URI()
+		schema.SetDraft(test.draft)
+		if err := schema.Validate(); err != nil {
+			t.Errorf("Expected schema to be valid for draft %s, but got: %v", test.draft, err)
+		}
+
+		jsonStr, err := json.Marshal(&schema)
+		if err != nil {
+			t.Fatalf("Error while marshaling schema: %v", err)
+		}
+		assert.Equal(t, string(jsonStr), test.expected)
+	}
+}
+
+func TestDependenciesKeyword(t *testing.T) {
+	yamlData := `
+dependencies:
+  tls: [certificate]
+  auth:
+    required: [password]
+`
+	var schema Schema
+	if err := yaml.Unmarshal([]byte(yamlData), &schema); err != nil {
+		t.Fatalf("Error while reading test data: %v", err)
+	}
+	assert.Equal(t, schema.DependentRequired, map[string][]string{"tls": {"certificate"}})
+	if schema.DependentSchemas["auth"] == nil {
+		t.Fatalf("Expected a dependent schema for auth, but got %v", schema.DependentSchemas)
+	}
+	assert.Equal(t, schema.DependentSchemas["auth"].Required.Strings, []string{"password"})
+
+	schema.SetDraft(Draft2019)
+	schema.Contains = &Schema{Type: StringOrArrayOfString{"string"}}
+	minContains := 1
+	schema.MinContains = &minContains
+	if err := schema.Validate(); err != nil {
+		t.Errorf("Expected minContains to be valid for draft %s, but got: %v", Draft2019, err)
+	}
+}
+
+func TestEnumAndExamplesKeepTypes(t *testing.T) {
+	tests := []struct {
+		comment  string
+		expected string
+	}{
+		{
+			comment: `
+# @schema
+# enum: [1, "2", true, null]
+# @schema`,
+			expected: `{"enum":[1,"2",true,null],"required":[]}`,
+		},
+		{
+			comment: `
+# @schema
+# examples:
+#   - 1.5
+#   - name: foo
+#     ports: [80]
+# @schema`,
+			expected: `{"examples":[1.5,{"name":"foo","ports":[80]}],"required":[]}`,
+		},
+	}
+
+	for _, test := range tests {
+		schema, _, err := GetSchemaFromComment(test.comment)
+		if err != nil {
+			t.Fatalf("Error while reading test data: %v", err)
+		}
+		if err := schema.Validate(); err != nil {
+			t.Errorf("Expected schema\n%s\n\n to be valid, but got: %v", test.comment, err)
+		}
+
+		jsonStr, err := json.Marshal(&schema)
+		if err != nil {
+			t.Fatalf("Error while marshaling schema: %v", err)
+		}
+		assert.Equal(t, string(jsonStr), test.expected)
+	}
+}
+
+func TestToOrderedJson(t *testing.T) {
+	generator := NewGenerator(DefaultGeneratorOptions())
+	schema, err := generator.GenerateFromValues(strings.NewReader(`
+zeta: 1
+# @schema
+# description: first
+# type: integer
+# minimum: 0
+# @schema
+alpha: 2
+middle:
+  b: true
+  a: false
+list:
+  - name: x
+    age: 1
+`))
+	if err != nil {
+		t.Fatalf("Error while generating schema: %v", err)
+	}
+
+	jsonStr, err := schema.ToOrderedJson()
+	if err != nil {
+		t.Fatalf("Error while marshaling schema: %v", err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, jsonStr); err != nil {
+		t.Fatalf("Error while compacting json: %v", err)
+	}
+	assert.Equal(t, compact.String(), `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","required":["zeta","middle","list"],"properties":{"zeta":{"title":"zeta","type":"integer","default":1,"required":[]},"alpha":{"title":"alpha","description":"first","type":"integer","default":2,"minimum":0,"required":[]},"middle":{"title":"middle","type":"object","required":["b","a"],"properties":{"b":{"title":"b","type":"boolean","default":true,"required":[]},"a":{"title":"a","type":"boolean","default":false,"required":[]}},"additionalProperties":false},"list":{"title":"list","type":"array","required":[],"items":{"type":"object","required":["name","age"],"properties":{"name":{"title":"name","type":"string","default":"x","required":[]},"age":{"title":"age","type":"integer","default":1,"required":[]}},"additionalProperties":false}},"global":{"title":"global","description":"Global values are values that can be accessed from any chart or subchart by exactly the same name.","type":"object","required":[]}},"additionalProperties":false}`)
+
+	unordered, err := schema.ToJson()
+	if err != nil {
+		t.Fatalf("Error while marshaling schema: %v", err)
+	}
+	var orderedData, unorderedData interface{}
+	_ = json.Unmarshal(jsonStr, &orderedData)
+	_ = json.Unmarshal(unordered, &unorderedData)
+	assert.Equal(t, orderedData, unorderedData)
+}
+
+func TestInferItemsSchema(t *testing.T) {
+	generator := NewGenerator(DefaultGeneratorOptions())
+	schema, err := generator.GenerateFromValues(strings.NewReader(`
+strings: [a, b, c]
+objects:
+  - name: a
+    port: 80
+  - name: b
+    port: http
+    tls: true
+mixed: [a, 1, b, {name: c}]
+empty: []
+`))
+	if err != nil {
+		t.Fatalf("Error while generating schema: %v", err)
+	}
+
+	tests := []struct {
+		property string
+		expected string
+	}{
+		{
+			property: "strings",
+			expected: `{"required":[],"type":"string"}`,
+		},
+		{
+			property: "objects",
+			expected: `{"additionalProperties":false,"properties":{"name":{"default":"a","required":[],"title":"name","type":"string"},"port":{"default":80,"required":[],"title":"port","type":["integer","string"]},"tls":{"default":true,"required":[],"title":"tls","type":"boolean"}},"required":["name","port"],"type":"object"}`,
+		},
+		{
+			property: "mixed",
+			expected: `{"anyOf":[{"required":[],"type":"string"},{"required":[],"type":"integer"},{"additionalProperties":false,"properties":{"name":{"default":"c","required":[],"title":"name","type":"string"}},"required":["name"],"type":"object"}],"required":[]}`,
+		},
+		{
+			property: "empty",
+			expected: `{"required":[]}`,
+		},
+	}
+
+	for _, test := range tests {
+		jsonStr, err := json.Marshal(schema.Properties[test.property].Items)
+		if err != nil {
+			t.Fatalf("Error while marshaling schema: %v", err)
+		}
+		assert.Equal(t, string(jsonStr), test.expected, test.property)
+	}
+}
+
+func TestYamlMergeKeys(t *testing.T) {
+	generator := NewGenerator(DefaultGeneratorOptions())
+	schema, err := generator.GenerateFromValues(strings.NewReader(`
+defaults: &defaults
+  # @schema
+  # type: integer
+  # minimum: 1
+  # @schema
+  replicas: 1
+  image: base
+resources: &resources
+  cpu: 100m
+  image: other
+app:
+  <<: [*defaults, *resources]
+  image: app
+  port: 80
+`))
+	if err != nil {
+		t.Fatalf("Error while generating schema: %v", err)
+	}
+
+	app := schema.Properties["app"]
+	if _, ok := app.Properties["<<"]; ok {
+		t.Error("Expected the merge key to be expanded, but found a << property")
+	}
+	assert.Equal(t, app.PropertyNames(), []string{"replicas", "cpu", "image", "port"})
+	assert.Equal(t, app.Properties["image"].Default, "app")
+	assert.Equal(t, *app.Properties["replicas"].Minimum, Number("1"))
+	assert.Equal(t, app.Properties["cpu"].Default, "100m")
+
+	_, err = generator.GenerateFromValues(strings.NewReader(`
+scalar: &scalar foo
+app:
+  <<: *scalar
+`))
+	var valuesErr *ValuesError
+	if !errors.As(err, &valuesErr) {
+		t.Fatalf("Expected a ValuesError, but got %v", err)
+	}
+	assert.Equal(t, valuesErr.Line, 4)
+}
+
+func TestNumberRoundTripLarge(t *testing.T) {
+	var s Schema
+	values := "maximum: 9007199254740993\nminimum: 1.0\nmultipleOf: 123456789012345678901234567890\n"
+	if err := yaml.Unmarshal([]byte(values), &s); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+
+	for name, toJson := range map[string]func() ([]byte, error){"ToJson": s.ToJson, "ToOrderedJson": s.ToOrderedJson} {
+		jsonStr, err := toJson()
+		if err != nil {
+			t.Fatalf("%s: wasn't expecting an error, but got this: %v", name, err)
+		}
+		for _, expected := range []string{`"maximum": 9007199254740993`, `"minimum": 1.0`, `"multipleOf": 123456789012345678901234567890`} {
+			if !strings.Contains(string(jsonStr), expected) {
+				t.Errorf("%s: expected %s in %s", name, expected, jsonStr)
+			}
+		}
+	}
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	var s Schema
+	if err := yaml.Unmarshal([]byte("minimum: 0.10\nmaximum: 1e3\nmultipleOf: 0x10\n"), &s); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	jsonStr, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	for _, expected := range []string{`"minimum":0.10`, `"maximum":1e3`, `"multipleOf":16`} {
+		if !strings.Contains(string(jsonStr), expected) {
+			t.Errorf("Expected %s in %s", expected, jsonStr)
+		}
+	}
+
+	var parsed Schema
+	if err := json.Unmarshal(jsonStr, &parsed); err != nil {
+		t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+	}
+	assert.Equal(t, *parsed.Minimum, Number("0.10"))
+	assert.Equal(t, parsed.Maximum.Cmp(Number("1000")), 0)
+
+	for _, invalid := range []string{"minimum: abc", "minimum: .inf", "maximum: [1]"} {
+		if err := yaml.Unmarshal([]byte(invalid), &Schema{}); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
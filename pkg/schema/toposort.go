@@ -4,80 +4,64 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Masterminds/semver/v3"
 	mapset "github.com/deckarep/golang-set/v2"
-	"github.com/winterRel/helm-schema/pkg/chart"
 )
 
-// TopoSort uses topological sorting to sort the results
+// TopoSort uses topological sorting to sort the results, so the charts
+// used as dependencies are placed before the charts depending on them
 func TopoSort(results []*Result) ([]*Result, error) {
-	// Map result identifier to result
-	lookup := make(map[string][]*Result)
+	resolver := newDependencyResolver(results)
 
-	// Map result identifier to dependencies identifiers
-	todo := make(map[string]mapset.Set[chart.Dependency])
+	// Map result to the results of its dependencies
+	todo := make(map[*Result]mapset.Set[*Result])
 
 	// Create the work queue
 	for _, result := range results {
-		dependencies := mapset.NewSet[chart.Dependency]()
+		dependencies := mapset.NewSet[*Result]()
 		for _, dep := range result.Chart.Dependencies {
-			dependencies.Add(*dep)
+			if dependencyResult := resolver.resolve(result, dep); dependencyResult != nil {
+				dependencies.Add(dependencyResult)
+			}
 		}
-		resultId := fmt.Sprintf("%s|%s", result.Chart.Name, result.Chart.Version)
-		lookup[resultId] = append(lookup[resultId], result)
-		todo[resultId] = dependencies
+		todo[result] = dependencies
 	}
 
 	var sorted []*Result
 
 	// if we have work left
 	for len(todo) != 0 {
-		ready := mapset.NewSet[string]()
-		for name, deps := range todo {
+		var ready []*Result
+		for _, result := range results {
 			// if no further deps found (end of the dep tree), this chart is ready
-			if deps.Cardinality() == 0 {
-				ready.Add(name)
+			if deps, ok := todo[result]; ok && deps.Cardinality() == 0 {
+				ready = append(ready, result)
 			}
 		}
 
 		// if no items are ready, we are stuck
-		if ready.Cardinality() == 0 {
+		if len(ready) == 0 {
 			// append unsorted to sorted items and return them
-			for name := range todo {
-				sorted = append(sorted, lookup[name]...)
+			var stuck []string
+			for _, result := range results {
+				if _, ok := todo[result]; ok {
+					sorted = append(sorted, result)
+					stuck = append(stuck, fmt.Sprintf("%s (%s)", result.Chart.Name, result.ChartPath))
+				}
 			}
 
-			return sorted, &CircularError{fmt.Sprintf("circular or missing dependency found: %v - Please build and untar all your helm dependencies: helm dep build && ls charts/*.tgz |xargs -n1 tar -C charts/ -xzf", todo)}
+			return sorted, &CircularError{fmt.Sprintf("circular dependency found: %s", strings.Join(stuck, ", "))}
 		}
 
 		// remove ready items from todo list and add to sorted list
-		for name := range ready.Iter() {
-			delete(todo, name)
-			sorted = append(sorted, lookup[name]...)
+		for _, result := range ready {
+			delete(todo, result)
+			sorted = append(sorted, result)
 
 			// remove ready items from deps list too
-			for todoName, deps := range todo {
-				newDeps := deps.Clone()
-				for dep := range deps.Iter() {
-					c, err := semver.NewConstraint(dep.Version)
-					if err != nil {
-						return sorted, err
-					}
-
-					nameVersion := strings.Split(name, "|")
-					sem, err := semver.NewVersion(nameVersion[1])
-					if err != nil {
-						return sorted, err
-					}
-
-					if c.Check(sem) {
-						newDeps.Remove(dep)
-					}
-				}
-				todo[todoName] = newDeps
+			for _, deps := range todo {
+				deps.Remove(result)
 			}
 		}
-
 	}
 	return sorted, nil
 }
@@ -0,0 +1,222 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Violation describes a single place where values don't match a jsonschema
+type Violation struct {
+	ValuesPath string
+	// Pointer is the json pointer of the failing key (e.g. /image/tag)
+	Pointer string
+	// Line and Column of the failing key, zero if unknown
+	Line    int
+	Column  int
+	Message string
+}
+
+func (v *Violation) Error() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	location := v.ValuesPath
+	if v.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", location, v.Line, v.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, pointer, v.Message)
+}
+
+// Compile compiles the schema, so it can be used to validate values
+func (s Schema) Compile() (*jsonschema.Schema, error) {
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		return nil, err
+	}
+	return CompileJSON(jsonStr)
+}
+
+// CompileJSON compiles a jsonschema document (e.g. an existing values.schema.json).
+// The draft is read from $schema and defaults to draft 7 like helm does.
+func CompileJSON(jsonStr []byte) (*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft7
+	if err := c.AddResource("schema.json", bytes.NewReader(jsonStr)); err != nil {
+		return nil, err
+	}
+	return c.Compile("schema.json")
+}
+
+// ValidateValues validates the yaml values read from valuesPath against the compiled jsonschema.
+// It returns every violation found, the error is only set if the values can't be read.
+func ValidateValues(compiled *jsonschema.Schema, valuesPath string, content []byte) ([]*Violation, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, err
+	}
+
+	doc, err := yamlNodeToJSON(&node)
+	if err != nil {
+		return nil, err
+	}
+
+	err = compiled.Validate(doc)
+	if err == nil {
+		return nil, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	var violations []*Violation
+	seen := make(map[string]bool)
+	for _, cause := range leafCauses(validationErr) {
+		if seen[cause.InstanceLocation+cause.Message] {
+			continue
+		}
+		seen[cause.InstanceLocation+cause.Message] = true
+
+		violation := &Violation{
+			ValuesPath: valuesPath,
+			Pointer:    cause.InstanceLocation,
+			Message:    cause.Message,
+		}
+		if valueNode := nodeForPointer(&node, cause.InstanceLocation); valueNode != nil {
+			violation.Line = valueNode.Line
+			violation.Column = valueNode.Column
+		}
+		violations = append(violations, violation)
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Line < violations[j].Line
+	})
+
+	return violations, nil
+}
+
+// InvalidValueError is returned if a value of a keyword (e.g. enum, examples or default)
+// doesn't match the jsonschema it belongs to
+type InvalidValueError struct {
+	Keyword string
+	// Value is the value as json
+	Value    string
+	Messages []string
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("%s value %s doesn't match the schema: %s", e.Keyword, e.Value, strings.Join(e.Messages, ", "))
+}
+
+// validateValue validates a value of the keyword (e.g. default) against the compiled jsonschema
+func validateValue(compiled *jsonschema.Schema, keyword string, value interface{}) error {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%s value %v can't be converted to json: %w", keyword, value, err)
+	}
+	doc, err := decodeJSON(jsonValue)
+	if err != nil {
+		return err
+	}
+
+	err = compiled.Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		invalidValueErr := &InvalidValueError{Keyword: keyword, Value: string(jsonValue)}
+		for _, cause := range leafCauses(validationErr) {
+			invalidValueErr.Messages = append(invalidValueErr.Messages, cause.Message)
+		}
+		return invalidValueErr
+	}
+	return err
+}
+
+// leafCauses returns the most specific errors of the validation error
+func leafCauses(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var result []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		result = append(result, leafCauses(cause)...)
+	}
+	return result
+}
+
+// yamlNodeToJSON converts the yaml document into a value the jsonschema library can validate.
+// Like helm, an empty document is treated as an empty map.
+func yamlNodeToJSON(node *yaml.Node) (interface{}, error) {
+	var values interface{}
+	if err := node.Decode(&values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	jsonStr, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(jsonStr)
+}
+
+// nodeForPointer returns the yaml node the json pointer points to. For mapping entries
+// the key node is returned, because that is what users search for.
+// If the pointer can't be followed completely, the deepest node found is returned.
+func nodeForPointer(node *yaml.Node, pointer string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return node
+		}
+		node = node.Content[0]
+	}
+	if pointer == "" {
+		return node
+	}
+
+	// position is the node reported, current the node we descend into
+	position, current := node, node
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		if current.Kind == yaml.AliasNode {
+			current = current.Alias
+		}
+
+		found := false
+		switch current.Kind {
+		case yaml.MappingNode:
+			content, err := expandMergeKeys(current)
+			if err != nil {
+				content = current.Content
+			}
+			for i := 0; i+1 < len(content); i += 2 {
+				if content[i].Value == token {
+					position, current = content[i], content[i+1]
+					found = true
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(token); err == nil && index >= 0 && index < len(current.Content) {
+				position, current = current.Content[index], current.Content[index]
+				found = true
+			}
+		}
+
+		if !found {
+			break
+		}
+	}
+	return position
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestValidateValues(t *testing.T) {
+	generator := NewGenerator(DefaultGeneratorOptions())
+	schema, err := generator.GenerateFromValues(strings.NewReader(`
+image:
+  # @schema
+  # type: string
+  # @schema
+  tag: latest
+replicas: 1
+hosts:
+  - name: example.com
+`))
+	assert.Equal(t, err, nil)
+
+	compiled, err := schema.Compile()
+	assert.Equal(t, err, nil)
+
+	tests := []struct {
+		name     string
+		values   string
+		expected []string
+	}{
+		{
+			name:     "valid",
+			values:   "image:\n  tag: v1\nreplicas: 3\nhosts: []\n",
+			expected: nil,
+		},
+		{
+			name:     "empty document",
+			values:   "",
+			expected: []string{"prod.yaml: /: missing properties: 'image', 'replicas', 'hosts'"},
+		},
+		{
+			name:     "wrong type",
+			values:   "image:\n  tag: 1\nreplicas: 3\nhosts: []\n",
+			expected: []string{"prod.yaml:2:3: /image/tag: expected string, but got number"},
+		},
+		{
+			name:   "unknown keys",
+			values: "image: {}\nreplicas: 3\nhosts:\n  - name: example.com\n    port: 80\nfoo: bar\n",
+			expected: []string{
+				"prod.yaml:1:1: /: additionalProperties 'foo' not allowed",
+				"prod.yaml:4:5: /hosts/0: additionalProperties 'port' not allowed",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations, err := ValidateValues(compiled, "prod.yaml", []byte(test.values))
+			assert.Equal(t, err, nil)
+
+			var actual []string
+			for _, violation := range violations {
+				actual = append(actual, violation.Error())
+			}
+			assert.Equal(t, actual, test.expected)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		from   string
+		to     string
+		output string
+	}{
+		{
+			from:   "foo\nbar\n",
+			to:     "foo\nbar\n",
+			output: "",
+		},
+		{
+			from:   "foo\nbar\nbaz\n",
+			to:     "foo\nqux\nbaz\n",
+			output: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n foo\n-bar\n+qux\n baz\n",
+		},
+		{
+			from:   "",
+			to:     "foo\n",
+			output: "--- a\n+++ b\n@@ -0,0 +1 @@\n+foo\n",
+		},
+		{
+			from:   "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:     "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			output: "--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+	for _, test := range tests {
+		diff := UnifiedDiff("a", "b", []byte(test.from), []byte(test.to))
+		if diff != test.output {
+			t.Errorf("Was expecting\n%s\nbut got\n%s", test.output, diff)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type globPattern struct {
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// compileGlob converts a gitignore like glob pattern to a regular expression.
+// Patterns without a slash match at any level, ** matches any number of directories
+// and a pattern matching a directory matches everything inside of it as well.
+func compileGlob(pattern string) (*globPattern, error) {
+	glob := &globPattern{}
+	if strings.HasPrefix(pattern, "!") {
+		glob.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		glob.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		expr.WriteString("(?:.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("(/.*)?$")
+
+	regex, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	glob.regex = regex
+	return glob, nil
+}
+
+// matches checks if the slash separated path matches the pattern
+func (g *globPattern) matches(path string, isDir bool) bool {
+	match := g.regex.FindStringSubmatch(path)
+	if match == nil {
+		return false
+	}
+	// dir only patterns match files inside of the directory, but not files with the same name
+	return !g.dirOnly || isDir || match[1] != ""
+}
+
+// PathMatcher matches slash separated relative paths against gitignore like glob patterns
+type PathMatcher struct {
+	patterns []*globPattern
+}
+
+// NewPathMatcher compiles the patterns, patterns starting with ! exclude previously matched paths again
+func NewPathMatcher(patterns []string) (*PathMatcher, error) {
+	matcher := &PathMatcher{}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		glob, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matcher.patterns = append(matcher.patterns, glob)
+	}
+	return matcher, nil
+}
+
+// ReadIgnoreFile parses a .gitignore or .helmignore file
+func ReadIgnoreFile(reader io.Reader) (*PathMatcher, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewPathMatcher(patterns)
+}
+
+// Match checks if the path is matched by the patterns
+func (m *PathMatcher) Match(path string, isDir bool) bool {
+	matched, _ := m.match(path, isDir)
+	return matched
+}
+
+// match returns if the path is matched and if any pattern decided about it
+func (m *PathMatcher) match(path string, isDir bool) (matched, decided bool) {
+	for _, pattern := range m.patterns {
+		if pattern.matches(path, isDir) {
+			matched, decided = !pattern.negate, true
+		}
+	}
+	return matched, decided
+}
+
+// Ignorer checks paths below root against the ignore files (e.g. .gitignore) of their parent directories
+type Ignorer struct {
+	root      string
+	fileNames []string
+	matchers  map[string][]*PathMatcher
+}
+
+// NewIgnorer creates an Ignorer which respects the ignore files with the given names
+func NewIgnorer(root string, fileNames ...string) *Ignorer {
+	return &Ignorer{root: root, fileNames: fileNames, matchers: make(map[string][]*PathMatcher)}
+}
+
+// Ignored checks if the path is ignored. Like git, ignore files in deeper directories
+// take precedence over the ones closer to the root.
+func (i *Ignorer) Ignored(filePath string, isDir bool) (bool, error) {
+	rel, err := filepath.Rel(i.root, filePath)
+	if err != nil {
+		return false, err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return false, nil
+	}
+
+	dirs := []string{"."}
+	if parent := path.Dir(rel); parent != "." {
+		segments := strings.Split(parent, "/")
+		for n := range segments {
+			dirs = append(dirs, strings.Join(segments[:n+1], "/"))
+		}
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		matchers, err := i.load(dir)
+		if err != nil {
+			return false, err
+		}
+		relToDir := strings.TrimPrefix(rel, dir+"/")
+		for _, matcher := range matchers {
+			if matched, decided := matcher.match(relToDir, isDir); decided {
+				ignored = matched
+			}
+		}
+	}
+	return ignored, nil
+}
+
+// load reads the ignore files of dir (relative to root)
+func (i *Ignorer) load(dir string) ([]*PathMatcher, error) {
+	if matchers, ok := i.matchers[dir]; ok {
+		return matchers, nil
+	}
+
+	var matchers []*PathMatcher
+	for _, fileName := range i.fileNames {
+		file, err := os.Open(filepath.Join(i.root, filepath.FromSlash(dir), fileName))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		matcher, err := ReadIgnoreFile(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	i.matchers[dir] = matchers
+	return matchers, nil
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathMatcher(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{[]string{"node_modules"}, "node_modules", true, true},
+		{[]string{"node_modules"}, "web/node_modules/chart/Chart.yaml", false, true},
+		{[]string{"/node_modules"}, "web/node_modules", true, false},
+		{[]string{"tests/fixtures"}, "tests/fixtures/chart", true, true},
+		{[]string{"tests/fixtures"}, "other/tests/fixtures", true, false},
+		{[]string{"**/fixtures"}, "other/tests/fixtures", true, true},
+		{[]string{"charts/*"}, "charts/a", true, true},
+		{[]string{"charts/*"}, "charts", true, false},
+		{[]string{"charts/**/Chart.yaml"}, "charts/Chart.yaml", false, true},
+		{[]string{"charts/**/Chart.yaml"}, "charts/a/b/Chart.yaml", false, true},
+		{[]string{"*.tgz"}, "charts/dep-1.0.0.tgz", false, true},
+		{[]string{"chart-?"}, "chart-a", true, true},
+		{[]string{"chart-[ab]"}, "chart-c", true, false},
+		{[]string{"build/"}, "build", false, false},
+		{[]string{"build/"}, "build", true, true},
+		{[]string{"build/"}, "build/Chart.yaml", false, true},
+		{[]string{"charts/*", "!charts/keep"}, "charts/keep", true, false},
+		{[]string{"charts/*", "!charts/keep"}, "charts/drop", true, true},
+	}
+
+	for _, test := range tests {
+		matcher, err := NewPathMatcher(test.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := matcher.Match(test.path, test.isDir); actual != test.expected {
+			t.Errorf("%v on %s: expected %t, got %t", test.patterns, test.path, test.expected, actual)
+		}
+	}
+}
+
+func TestIgnorer(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":           "vendor/\n# comment\n*.tgz\n",
+		"chart/.helmignore":    "!keep.tgz\n",
+		"chart/charts/dep.tgz": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ignorer := NewIgnorer(root, ".gitignore", ".helmignore")
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"vendor", true, true},
+		{"chart", true, false},
+		{"chart/charts/dep.tgz", false, true},
+		{"chart/charts/keep.tgz", false, false},
+	}
+	for _, test := range tests {
+		actual, err := ignorer.Ignored(filepath.Join(root, test.path), test.isDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.path, test.expected, actual)
+		}
+	}
+}